@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/charmbracelet/ssh"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// aclConfig configures which sources may connect at all. A source matching
+// the allowlist is always accepted, one matching only the denylist is dropped
+// right after accept. Entries are IPs or CIDRs.
+type aclConfig struct {
+	Allow []string `json:"allow"`
+	Deny  []string `json:"deny"`
+	// AllowFiles and DenyFiles contain one entry per line, lines starting
+	// with # are comments. The files are reloaded when they change.
+	AllowFiles []string `json:"allow_files"`
+	DenyFiles  []string `json:"deny_files"`
+	// DefaultDeny drops every source that isn't allowlisted.
+	DefaultDeny bool `json:"default_deny"`
+	// ReloadInterval is how often the files are checked for changes.
+	ReloadInterval duration `json:"reload_interval"`
+}
+
+var aclDenied = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "bozo_acl_denied_total",
+	Help: "Connections dropped by the allow and deny lists.",
+})
+
+type acl struct {
+	cfg aclConfig
+	bus *eventBus
+
+	mu     sync.RWMutex
+	allow  []*net.IPNet
+	deny   []*net.IPNet
+	mtimes map[string]time.Time
+}
+
+func newACL(cfg aclConfig, bus *eventBus) (*acl, error) {
+	a := &acl{cfg: cfg, bus: bus, mtimes: make(map[string]time.Time)}
+	if err := a.reload(); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// parseCIDR parses a CIDR, or a single IP as a /32 or /128.
+func parseCIDR(s string) (*net.IPNet, error) {
+	if !strings.Contains(s, "/") {
+		ip := net.ParseIP(s)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid IP %q", s)
+		}
+		bits := 128
+		if ip.To4() != nil {
+			ip, bits = ip.To4(), 32
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+	}
+	_, n, err := net.ParseCIDR(s)
+	return n, err
+}
+
+func parseCIDRs(entries []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(entries))
+	for _, e := range entries {
+		n, err := parseCIDR(e)
+		if err != nil {
+			return nil, err
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+func readCIDRFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var entries []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		entries = append(entries, line)
+	}
+	return entries, sc.Err()
+}
+
+// loadCIDRs parses the entries from the config and all files.
+func loadCIDRs(entries []string, files []string, mtimes map[string]time.Time) ([]*net.IPNet, error) {
+	entries = append([]string(nil), entries...)
+	for _, path := range files {
+		fi, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		mtimes[path] = fi.ModTime()
+		fileEntries, err := readCIDRFile(path)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, fileEntries...)
+	}
+	return parseCIDRs(entries)
+}
+
+// reload reads all lists again. On error the old lists stay in place.
+func (a *acl) reload() error {
+	mtimes := make(map[string]time.Time)
+	allow, err := loadCIDRs(a.cfg.Allow, a.cfg.AllowFiles, mtimes)
+	if err != nil {
+		return fmt.Errorf("allowlist: %w", err)
+	}
+	deny, err := loadCIDRs(a.cfg.Deny, a.cfg.DenyFiles, mtimes)
+	if err != nil {
+		return fmt.Errorf("denylist: %w", err)
+	}
+	a.mu.Lock()
+	a.allow, a.deny, a.mtimes = allow, deny, mtimes
+	a.mu.Unlock()
+	return nil
+}
+
+// changed reports whether any of the list files was modified since the last
+// reload.
+func (a *acl) changed() bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	for path, mtime := range a.mtimes {
+		fi, err := os.Stat(path)
+		if err != nil || !fi.ModTime().Equal(mtime) {
+			return true
+		}
+	}
+	return false
+}
+
+// watch reloads the list files when they change. It never returns.
+func (a *acl) watch() {
+	if len(a.cfg.AllowFiles)+len(a.cfg.DenyFiles) == 0 || a.cfg.ReloadInterval <= 0 {
+		return
+	}
+	for range time.Tick(time.Duration(a.cfg.ReloadInterval)) {
+		if !a.changed() {
+			continue
+		}
+		if err := a.reload(); err != nil {
+			log.Error("Could not reload allow and deny lists", "error", err)
+			continue
+		}
+		log.Info("Reloaded allow and deny lists")
+	}
+}
+
+func contains(nets []*net.IPNet, ip net.IP) bool {
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowed reports whether ip may connect.
+func (a *acl) allowed(ip net.IP) bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if contains(a.allow, ip) {
+		return true
+	}
+	return !a.cfg.DefaultDeny && !contains(a.deny, ip)
+}
+
+// connCallback drops connections of sources that aren't allowed.
+func (a *acl) connCallback(_ ssh.Context, conn net.Conn) net.Conn {
+	ip := remoteIP(conn.RemoteAddr())
+	if ip == nil || a.allowed(ip) {
+		return conn
+	}
+	aclDenied.Inc()
+	a.bus.publish(event{Type: eventAbuse, IP: ip, Reason: "denylisted"})
+	return nil
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	connectionsActive = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "bozo_connections_active",
+		Help: "Connections currently open.",
+	})
+	connectionsBusy = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "bozo_connections_busy_total",
+		Help: "Connections turned away because the server was overloaded.",
+	})
+)
+
+// admissionConfig bounds the number of open connections. Once HighWatermark
+// connections are open, new ones are turned away with a short busy message
+// until the number drops below LowWatermark again, instead of having every
+// session's frame rate degrade.
+type admissionConfig struct {
+	// HighWatermark is the number of open connections at which the server
+	// counts as overloaded, 0 disables the limit. The default is above the
+	// default max_sessions, it only matters once max_sessions is raised or
+	// for connections that never get to a session.
+	HighWatermark int `json:"high_watermark"`
+	LowWatermark  int `json:"low_watermark"`
+}
+
+const busyMessage = "Server busy, bozo. Try again later.\r\n"
+
+// admission counts the open connections of all listeners.
+type admission struct {
+	cfg        admissionConfig
+	mu         sync.Mutex
+	open       int
+	overloaded bool
+}
+
+func newAdmission(cfg admissionConfig) *admission {
+	// Without a sensible low watermark the server would never recover.
+	if cfg.LowWatermark <= 0 || cfg.LowWatermark > cfg.HighWatermark {
+		cfg.LowWatermark = cfg.HighWatermark
+	}
+	return &admission{cfg: cfg}
+}
+
+// admit counts a new connection and reports whether it may be served.
+func (a *admission) admit() bool {
+	if a.cfg.HighWatermark <= 0 {
+		return true
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if !a.overloaded && a.open >= a.cfg.HighWatermark {
+		a.overloaded = true
+		log.Warn("Server overloaded, turning away new connections", "open", a.open)
+	}
+	if a.overloaded {
+		return false
+	}
+	a.open++
+	connectionsActive.Inc()
+	return true
+}
+
+// release counts a closed connection.
+func (a *admission) release() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.open--
+	connectionsActive.Dec()
+	if a.overloaded && a.open < a.cfg.LowWatermark {
+		a.overloaded = false
+		log.Info("Server no longer overloaded", "open", a.open)
+	}
+}
+
+// admissionListener turns away the connections admission doesn't admit.
+type admissionListener struct {
+	net.Listener
+	admission *admission
+}
+
+func (l admissionListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+		if l.admission.admit() {
+			return &admittedConn{Conn: conn, admission: l.admission}, nil
+		}
+		connectionsBusy.Inc()
+		// SSH servers may send lines before their version, clients
+		// show them when the connection fails.
+		go func() {
+			conn.SetWriteDeadline(time.Now().Add(time.Second))
+			conn.Write([]byte(busyMessage))
+			conn.Close()
+		}()
+	}
+}
+
+// admittedConn releases its admission once it is closed.
+type admittedConn struct {
+	net.Conn
+	admission *admission
+	closeOnce sync.Once
+}
+
+func (c *admittedConn) Close() error {
+	c.closeOnce.Do(c.admission.release)
+	return c.Conn.Close()
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/charmbracelet/ssh"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// algorithmsConfig configures the key exchanges, ciphers and MACs the server
+// offers.
+type algorithmsConfig struct {
+	// Profile is "default" for the defaults of x/crypto/ssh or "weak" to
+	// accept legacy algorithms that old botnets still use as well.
+	Profile string `json:"profile"`
+	// KeyExchanges, Ciphers and MACs replace the lists of the profile when
+	// set.
+	KeyExchanges []string `json:"key_exchanges"`
+	Ciphers      []string `json:"ciphers"`
+	MACs         []string `json:"macs"`
+}
+
+// weakAlgorithms are the defaults of x/crypto/ssh followed by everything else
+// it supports. Clients still get the strong algorithms if they speak them.
+var weakAlgorithms = gossh.Config{
+	KeyExchanges: []string{
+		"curve25519-sha256", "curve25519-sha256@libssh.org",
+		"ecdh-sha2-nistp256", "ecdh-sha2-nistp384", "ecdh-sha2-nistp521",
+		"diffie-hellman-group14-sha256", "diffie-hellman-group16-sha512",
+		"diffie-hellman-group14-sha1", "diffie-hellman-group1-sha1",
+	},
+	Ciphers: []string{
+		"aes128-gcm@openssh.com", "aes256-gcm@openssh.com", "chacha20-poly1305@openssh.com",
+		"aes128-ctr", "aes192-ctr", "aes256-ctr",
+		"aes128-cbc", "3des-cbc", "arcfour256", "arcfour128", "arcfour",
+	},
+	MACs: []string{
+		"hmac-sha2-256-etm@openssh.com", "hmac-sha2-512-etm@openssh.com",
+		"hmac-sha2-256", "hmac-sha2-512", "hmac-sha1", "hmac-sha1-96",
+	},
+}
+
+// algorithms returns a server config hook applying the algorithm policy.
+func algorithms(cfg algorithmsConfig) (func(ssh.Context, *gossh.ServerConfig), error) {
+	var algos gossh.Config
+	switch cfg.Profile {
+	case "", "default":
+	case "weak":
+		algos = weakAlgorithms
+	default:
+		return nil, fmt.Errorf("unknown algorithm profile %q", cfg.Profile)
+	}
+	if len(cfg.KeyExchanges) > 0 {
+		algos.KeyExchanges = cfg.KeyExchanges
+	}
+	if len(cfg.Ciphers) > 0 {
+		algos.Ciphers = cfg.Ciphers
+	}
+	if len(cfg.MACs) > 0 {
+		algos.MACs = cfg.MACs
+	}
+	return func(_ ssh.Context, c *gossh.ServerConfig) {
+		c.Config = algos
+	}, nil
+}
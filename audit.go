@@ -0,0 +1,47 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+)
+
+// auditLog is an append-only log of administrative actions. Every entry is a
+// single JSON line.
+type auditLog struct {
+	mu sync.Mutex
+	f  *os.File
+}
+
+type auditEntry struct {
+	Time time.Time `json:"time"`
+	// Actor identifies who did it, e.g. an admin key fingerprint, or
+	// "signal:SIGUSR1" for actions triggered locally.
+	Actor  string `json:"actor"`
+	Action string `json:"action"`
+	Target string `json:"target,omitempty"`
+}
+
+func openAuditLog(path string) (*auditLog, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	return &auditLog{f: f}, nil
+}
+
+// record appends an entry and syncs it to disk, so it survives a crash right
+// after the action.
+func (a *auditLog) record(actor, action, target string) error {
+	b, err := json.Marshal(auditEntry{Time: time.Now(), Actor: actor, Action: action, Target: target})
+	if err != nil {
+		return err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if _, err := a.f.Write(append(b, '\n')); err != nil {
+		return err
+	}
+	return a.f.Sync()
+}
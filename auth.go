@@ -0,0 +1,369 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/charmbracelet/ssh"
+	"github.com/charmbracelet/wish"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// authConfig configures how visitors authenticate.
+type authConfig struct {
+	// AdminKeys is an authorized_keys file of the operators. Sessions
+	// authenticated with one of its keys get the stats screen and can run
+	// admin commands.
+	AdminKeys string `json:"admin_keys"`
+	// TrustedCAs is an authorized_keys file of SSH CAs. Certificates they
+	// signed for one of the AdminPrincipals grant admin access as well.
+	TrustedCAs      string   `json:"trusted_cas"`
+	AdminPrincipals []string `json:"admin_principals"`
+	// Challenge asks visitors a question before letting them in.
+	Challenge challengeConfig `json:"challenge"`
+	// MaxAttempts is the number of failed auth attempts after which a
+	// connection is closed. MaxDailyAttempts caps the attempts of a single
+	// source IP per day, 0 disables the cap.
+	MaxAttempts      int `json:"max_attempts"`
+	MaxDailyAttempts int `json:"max_daily_attempts"`
+}
+
+var authAttempts = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "bozo_auth_attempts_total",
+	Help: "Auth attempts, by method and outcome.",
+}, []string{"method", "outcome"})
+
+// challengeConfig configures the keyboard-interactive challenge. When it is
+// enabled, password auth is refused, so bots that can't answer stay out.
+type challengeConfig struct {
+	Enabled bool `json:"enabled"`
+	// Questions are asked at random.
+	Questions []challengeQuestion `json:"questions"`
+}
+
+type challengeQuestion struct {
+	Question string `json:"question"`
+	// Answers are the accepted answers, compared case-insensitively.
+	Answers []string `json:"answers"`
+}
+
+const (
+	adminKey        contextKey = "admin"
+	authAttemptsKey contextKey = "authAttempts"
+)
+
+// auth lets everyone in, but only operators with a listed key or a
+// certificate of a trusted CA as admins.
+//
+// Clients try the "none" method first and only offer their keys when it
+// fails, so it is never accepted. Keys that aren't listed are rejected, so the
+// client moves on to its next key, then to keyboard-interactive, which lets
+// everyone in without asking anything (or after the challenge), and finally to
+// password, which accepts anything.
+//
+// The publickey handler also runs for keys the client only asks about,
+// without proving it holds them. A session is only an admin one if the
+// publickey method completed, see completed.
+type auth struct {
+	challenge        challengeConfig
+	maxAttempts      int
+	maxDailyAttempts int
+	bus              *eventBus
+	backoff          *backoff
+
+	mu         sync.RWMutex
+	admins     []gossh.PublicKey
+	cas        []gossh.PublicKey
+	principals []string
+
+	attemptsMu sync.Mutex
+	day        string
+	attempts   map[string]int
+}
+
+func newAuth(cfg authConfig, bus *eventBus, backoff *backoff) (*auth, error) {
+	if cfg.Challenge.Enabled && len(cfg.Challenge.Questions) == 0 {
+		return nil, errors.New("the challenge needs at least one question")
+	}
+	a := &auth{
+		challenge:        cfg.Challenge,
+		maxAttempts:      cfg.MaxAttempts,
+		maxDailyAttempts: cfg.MaxDailyAttempts,
+		bus:              bus,
+		backoff:          backoff,
+		attempts:         make(map[string]int),
+	}
+	if err := a.reload(cfg); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// reload reads the admin keys and trusted CAs again.
+func (a *auth) reload(cfg authConfig) error {
+	admins, err := readAuthorizedKeys(cfg.AdminKeys)
+	if err != nil {
+		return err
+	}
+	cas, err := readAuthorizedKeys(cfg.TrustedCAs)
+	if err != nil {
+		return err
+	}
+	a.mu.Lock()
+	a.admins, a.cas, a.principals = admins, cas, cfg.AdminPrincipals
+	a.mu.Unlock()
+	log.Info("Loaded admin keys", "keys", len(admins), "cas", len(cas))
+	return nil
+}
+
+// readAuthorizedKeys reads the keys in the authorized_keys file at path. A
+// missing file has no keys.
+func readAuthorizedKeys(path string) ([]gossh.PublicKey, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var keys []gossh.PublicKey
+	for len(bytes.TrimSpace(data)) > 0 {
+		key, _, _, rest, err := gossh.ParseAuthorizedKey(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		keys = append(keys, key)
+		data = rest
+	}
+	return keys, nil
+}
+
+func (a *auth) isAdminKey(key ssh.PublicKey) bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return slices.ContainsFunc(a.admins, func(k gossh.PublicKey) bool { return ssh.KeysEqual(k, key) })
+}
+
+// isAdminCert reports whether cert is a valid user certificate of a trusted
+// CA for one of the admin principals.
+func (a *auth) isAdminCert(ctx ssh.Context, cert *gossh.Certificate) bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	i := slices.IndexFunc(cert.ValidPrincipals, func(p string) bool { return slices.Contains(a.principals, p) })
+	if i < 0 {
+		return false
+	}
+	checker := gossh.CertChecker{
+		IsUserAuthority: func(auth gossh.PublicKey) bool {
+			return slices.ContainsFunc(a.cas, func(k gossh.PublicKey) bool { return ssh.KeysEqual(k, auth) })
+		},
+	}
+	if !checker.IsUserAuthority(cert.SignatureKey) || cert.CertType != gossh.UserCert {
+		return false
+	}
+	if err := checker.CheckCert(cert.ValidPrincipals[i], cert); err != nil {
+		log.Debug("Rejected admin certificate", "remote", ctx.RemoteAddr(), "key_id", cert.KeyId, "error", err)
+		return false
+	}
+	return true
+}
+
+// attempt counts an auth attempt of ctx, delays it for repeat offenders and
+// reports whether the source may still try today.
+func (a *auth) attempt(ctx ssh.Context, method string) bool {
+	n, _ := ctx.Value(authAttemptsKey).(int)
+	ctx.SetValue(authAttemptsKey, n+1)
+	a.backoff.wait(ctx.RemoteAddr())
+	if a.maxDailyAttempts <= 0 {
+		return true
+	}
+	ip := remoteIP(ctx.RemoteAddr())
+	a.attemptsMu.Lock()
+	if day := time.Now().Format(time.DateOnly); day != a.day {
+		a.day = day
+		clear(a.attempts)
+	}
+	a.attempts[ip.String()]++
+	daily := a.attempts[ip.String()]
+	a.attemptsMu.Unlock()
+	if daily <= a.maxDailyAttempts {
+		return true
+	}
+	authAttempts.WithLabelValues(method, "limited").Inc()
+	if daily == a.maxDailyAttempts+1 {
+		log.Info("Source exceeded daily auth attempts", "remote", ip, "attempts", daily)
+		a.bus.publish(event{Type: eventAbuse, Time: time.Now(), IP: ip, Reason: "auth_limit"})
+	}
+	return false
+}
+
+// result counts the outcome of an auth attempt.
+func result(method string, ok bool) bool {
+	outcome := "failure"
+	if ok {
+		outcome = "success"
+	}
+	authAttempts.WithLabelValues(method, outcome).Inc()
+	return ok
+}
+
+// authenticated publishes a successful auth of ctx with method.
+func (a *auth) authenticated(ctx ssh.Context, method string) bool {
+	a.bus.publish(authEvent(ctx, method, true))
+	return result(method, true)
+}
+
+func authEvent(ctx ssh.Context, method string, success bool) event {
+	attempts, _ := ctx.Value(authAttemptsKey).(int)
+	return event{
+		Type:          eventAuth,
+		Time:          time.Now(),
+		SessionID:     ctx.SessionID(),
+		IP:            remoteIP(ctx.RemoteAddr()),
+		User:          ctx.User(),
+		ClientVersion: ctx.ClientVersion(),
+		Method:        method,
+		Success:       success,
+		Attempts:      attempts,
+	}
+}
+
+func (a *auth) publicKeyHandler(ctx ssh.Context, key ssh.PublicKey) bool {
+	rememberOfferedKey(ctx, key)
+	// All auth callbacks of a connection share its permissions, the options
+	// of an earlier key must not stick.
+	ctx.Permissions().CriticalOptions = nil
+	if !a.attempt(ctx, "publickey") {
+		return false
+	}
+	admin := a.isAdminKey(key)
+	if cert, ok := key.(*gossh.Certificate); ok {
+		admin = a.isAdminCert(ctx, cert)
+		if admin {
+			// x/crypto checks the source-address option right after the
+			// callback, and completed drops the options unless the client
+			// signs with this certificate.
+			ctx.Permissions().CriticalOptions = cert.CriticalOptions
+		}
+	}
+	return admin || result("publickey", false)
+}
+
+func (a *auth) keyboardInteractiveHandler(ctx ssh.Context, challenger gossh.KeyboardInteractiveChallenge) bool {
+	if !a.attempt(ctx, "keyboard-interactive") {
+		return false
+	}
+	if !a.challenge.Enabled {
+		return a.authenticated(ctx, "keyboard-interactive")
+	}
+	q := a.challenge.Questions[rand.IntN(len(a.challenge.Questions))]
+	answers, err := challenger("", "Prove you're not a bot.", []string{q.Question + " "}, []bool{true})
+	if err != nil || len(answers) != 1 {
+		return result("keyboard-interactive", false)
+	}
+	answer := strings.TrimSpace(answers[0])
+	e := authEvent(ctx, "keyboard-interactive", slices.ContainsFunc(q.Answers, func(s string) bool {
+		return strings.EqualFold(s, answer)
+	}))
+	e.Answer = answer
+	a.bus.publish(e)
+	return result("keyboard-interactive", e.Success)
+}
+
+func (a *auth) passwordHandler(ctx ssh.Context, _ string) bool {
+	if !a.attempt(ctx, "password") {
+		return false
+	}
+	if a.challenge.Enabled {
+		a.bus.publish(authEvent(ctx, "password", false))
+		return result("password", false)
+	}
+	return a.authenticated(ctx, "password")
+}
+
+// serverConfig applies the per-connection attempt limit and finishes the
+// auth of ctx once it completed.
+func (a *auth) serverConfig(ctx ssh.Context, cfg *gossh.ServerConfig) {
+	cfg.MaxAuthTries = a.maxAttempts
+	cfg.AuthLogCallback = func(_ gossh.ConnMetadata, method string, err error) {
+		if err == nil {
+			a.completed(ctx, method)
+		}
+	}
+}
+
+// completed marks ctx as an admin session if auth completed with publickey,
+// otherwise it drops the options of the certificates the client only asked
+// about.
+// x/crypto only calls back once the client signed with the key, it is the one
+// the handler accepted last as x/crypto caches a single key and calls the
+// handler again for any other. The handler only accepts admin keys.
+func (a *auth) completed(ctx ssh.Context, method string) {
+	if method != "publickey" {
+		ctx.Permissions().CriticalOptions = nil
+		return
+	}
+	ctx.SetValue(adminKey, true)
+	rememberVerifiedKey(ctx)
+	a.authenticated(ctx, "publickey")
+}
+
+// option installs the auth handlers.
+func (a *auth) option() ssh.Option {
+	return func(s *ssh.Server) error {
+		s.PublicKeyHandler = a.publicKeyHandler
+		s.KeyboardInteractiveHandler = a.keyboardInteractiveHandler
+		s.PasswordHandler = a.passwordHandler
+		return nil
+	}
+}
+
+// isAdmin reports whether the session was authenticated with an admin key.
+func isAdmin(ctx ssh.Context) bool {
+	admin, _ := ctx.Value(adminKey).(bool)
+	return admin
+}
+
+// adminCommandMiddleware runs the admin commands for admins that pass one,
+// e.g. "ssh host ban list". Everyone else gets the banner.
+func adminCommandMiddleware(cfg config, keys *hostKeys) wish.Middleware {
+	return func(next ssh.Handler) ssh.Handler {
+		return func(sess ssh.Session) {
+			if len(sess.Command()) == 0 || !isAdmin(sess.Context()) {
+				next(sess)
+				return
+			}
+			getSessionStats(sess).setOutcome("admin_command")
+			c := cli{
+				cfg:      cfg,
+				actor:    adminActor(sess),
+				stdout:   sess,
+				stderr:   sess.Stderr(),
+				hostKeys: keys,
+			}
+			sess.Exit(c.run(sess.Command()))
+		}
+	}
+}
+
+// adminActor identifies an admin in the audit log, by the ID of their
+// certificate or the fingerprint of their key.
+func adminActor(sess ssh.Session) string {
+	if cert, ok := sess.PublicKey().(*gossh.Certificate); ok {
+		return "ssh:" + sess.User() + ":cert:" + cert.KeyId
+	}
+	return "ssh:" + sess.User() + ":" + gossh.FingerprintSHA256(sess.PublicKey())
+}
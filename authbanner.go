@@ -0,0 +1,39 @@
+package main
+
+import (
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/ssh"
+	"github.com/charmbracelet/wish"
+)
+
+// authBannerConfig configures the banner sent during the ssh handshake, before
+// authentication. Clients show it even if they never get to request a PTY.
+type authBannerConfig struct {
+	// Text (or the contents of File) is the banner, "{ip}" is replaced with
+	// the address of the client. No banner is sent when both are empty.
+	Text string `json:"text"`
+	File string `json:"file"`
+}
+
+// withAuthBanner sends the configured pre-auth banner to every client.
+func withAuthBanner(cfg authBannerConfig) ssh.Option {
+	return func(s *ssh.Server) error {
+		text := cfg.Text
+		if cfg.File != "" {
+			b, err := os.ReadFile(cfg.File)
+			if err != nil {
+				return err
+			}
+			text = string(b)
+		}
+		if text == "" {
+			return nil
+		}
+		text = strings.TrimRight(text, "\n") + "\n"
+		return wish.WithBannerHandler(func(ctx ssh.Context) string {
+			return strings.ReplaceAll(text, "{ip}", remoteIP(ctx.RemoteAddr()).String())
+		})(s)
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"math"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/ssh"
+)
+
+// backoffConfig configures escalating delays for sources that keep abusing
+// the server.
+type backoffConfig struct {
+	// Delays are applied to the banner and auth phases once a source has an
+	// abuse score of 1, 2, 3 and so on. The last delay is used for all higher
+	// scores. Backoff is disabled when empty.
+	Delays []duration `json:"delays"`
+	// HalfLife is how long it takes for an abuse score to halve.
+	HalfLife duration `json:"half_life"`
+}
+
+type offender struct {
+	score   float64
+	updated time.Time
+}
+
+// backoff tracks an abuse score per source, raised by abuse events and failed
+// auths, and decaying over time.
+type backoff struct {
+	cfg backoffConfig
+
+	mu        sync.Mutex
+	offenders map[string]*offender
+}
+
+func newBackoff(cfg backoffConfig) *backoff {
+	return &backoff{cfg: cfg, offenders: make(map[string]*offender)}
+}
+
+// decay brings the score of o up to date. b.mu must be held.
+func (b *backoff) decay(o *offender, now time.Time) {
+	if b.cfg.HalfLife > 0 {
+		halves := float64(now.Sub(o.updated)) / float64(b.cfg.HalfLife)
+		o.score *= math.Pow(0.5, halves)
+	}
+	o.updated = now
+}
+
+// offend raises the abuse score of ip by one.
+func (b *backoff) offend(ip net.IP) {
+	if len(b.cfg.Delays) == 0 || ip == nil {
+		return
+	}
+	now := time.Now()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	o, ok := b.offenders[ip.String()]
+	if !ok {
+		o = &offender{updated: now}
+		b.offenders[ip.String()] = o
+	}
+	b.decay(o, now)
+	o.score++
+}
+
+// delay returns how long ip has to wait in the banner and auth phases.
+func (b *backoff) delay(ip net.IP) time.Duration {
+	if len(b.cfg.Delays) == 0 || ip == nil {
+		return 0
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	o, ok := b.offenders[ip.String()]
+	if !ok {
+		return 0
+	}
+	b.decay(o, time.Now())
+	if o.score < 1 {
+		return 0
+	}
+	i := min(int(o.score)-1, len(b.cfg.Delays)-1)
+	return time.Duration(b.cfg.Delays[i])
+}
+
+// subscribe raises the scores of sources of abuse events and failed auths.
+func (b *backoff) subscribe(bus *eventBus) {
+	bus.subscribe("backoff", 1024, func(e event) {
+		if e.Type == eventAbuse || e.Type == eventAuth && !e.Success {
+			b.offend(e.IP)
+		}
+	})
+}
+
+// connCallback delays repeat offenders before the server sends its version
+// banner.
+func (b *backoff) connCallback(_ ssh.Context, conn net.Conn) net.Conn {
+	time.Sleep(b.delay(remoteIP(conn.RemoteAddr())))
+	return conn
+}
+
+// wait sleeps for the auth delay of addr. The auth handlers call it.
+func (b *backoff) wait(addr net.Addr) {
+	time.Sleep(b.delay(remoteIP(addr)))
+}
+
+// cleanup forgets sources whose score decayed to almost nothing. It never
+// returns.
+func (b *backoff) cleanup() {
+	for range time.Tick(time.Minute) {
+		now := time.Now()
+		b.mu.Lock()
+		for ip, o := range b.offenders {
+			if b.decay(o, now); o.score < 0.1 {
+				delete(b.offenders, ip)
+			}
+		}
+		b.mu.Unlock()
+	}
+}
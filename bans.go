@@ -0,0 +1,310 @@
+package main
+
+import (
+	"database/sql"
+	"net"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/charmbracelet/ssh"
+	"github.com/charmbracelet/wish"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var bansEnforced = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "bozo_bans_enforced_total",
+	Help: "Connections dropped because the source is banned.",
+})
+
+// ban keeps a source out until it expires.
+type ban struct {
+	CIDR    string
+	Reason  string
+	Created time.Time
+	// Expires is zero for bans that never expire.
+	Expires time.Time
+}
+
+func (b ban) expired(now time.Time) bool {
+	return !b.Expires.IsZero() && !now.Before(b.Expires)
+}
+
+func (s *store) addBan(b ban) error {
+	var expires sql.NullInt64
+	if !b.Expires.IsZero() {
+		expires = sql.NullInt64{Int64: b.Expires.Unix(), Valid: true}
+	}
+	_, err := s.db.Exec(
+		`INSERT INTO bans (cidr, reason, created, expires) VALUES (?, ?, ?, ?)
+		ON CONFLICT (cidr) DO UPDATE SET reason = excluded.reason, created = excluded.created, expires = excluded.expires`,
+		b.CIDR, b.Reason, b.Created.Unix(), expires,
+	)
+	return err
+}
+
+func (s *store) removeBan(cidr string) (bool, error) {
+	res, err := s.db.Exec(`DELETE FROM bans WHERE cidr = ?`, cidr)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// bans returns all bans that haven't expired yet.
+func (s *store) bans(now time.Time) ([]ban, error) {
+	rows, err := s.db.Query(`SELECT cidr, reason, created, expires FROM bans WHERE expires IS NULL OR expires > ? ORDER BY created`, now.Unix())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var bans []ban
+	for rows.Next() {
+		var (
+			b       ban
+			created int64
+			expires sql.NullInt64
+		)
+		if err := rows.Scan(&b.CIDR, &b.Reason, &created, &expires); err != nil {
+			return nil, err
+		}
+		b.Created = time.Unix(created, 0)
+		if expires.Valid {
+			b.Expires = time.Unix(expires.Int64, 0)
+		}
+		bans = append(bans, b)
+	}
+	return bans, rows.Err()
+}
+
+func (s *store) deleteExpiredBans(now time.Time) error {
+	_, err := s.db.Exec(`DELETE FROM bans WHERE expires IS NOT NULL AND expires <= ?`, now.Unix())
+	return err
+}
+
+// ipCIDR returns ip as a single address CIDR, the form bans of a single IP
+// are stored in.
+func ipCIDR(ip net.IP) string {
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4.String() + "/32"
+	}
+	return ip.String() + "/128"
+}
+
+// banList is the set of banned sources, persisted in the store and enforced
+// right after accept as well as when a session starts. Bans added with the ban
+// command by another process are picked up on the next sync.
+type banList struct {
+	db  *store
+	bus *eventBus
+
+	mu   sync.RWMutex
+	ips  map[string]ban
+	nets []banNet
+}
+
+type banNet struct {
+	ban
+	net *net.IPNet
+}
+
+func loadBanList(db *store, bus *eventBus) (*banList, error) {
+	l := &banList{db: db, bus: bus}
+	if err := l.reload(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// reload replaces the in-memory bans with the ones in the store.
+func (l *banList) reload() error {
+	bans, err := l.db.bans(time.Now())
+	if err != nil {
+		return err
+	}
+	ips := make(map[string]ban)
+	var nets []banNet
+	for _, b := range bans {
+		n, err := parseCIDR(b.CIDR)
+		if err != nil {
+			log.Warn("Ignoring invalid ban", "cidr", b.CIDR, "error", err)
+			continue
+		}
+		if ones, bits := n.Mask.Size(); ones == bits {
+			ips[b.CIDR] = b
+		} else {
+			nets = append(nets, banNet{ban: b, net: n})
+		}
+	}
+	l.mu.Lock()
+	l.ips, l.nets = ips, nets
+	l.mu.Unlock()
+	return nil
+}
+
+// banIP bans ip for ttl, or forever if ttl is 0.
+func (l *banList) banIP(ip net.IP, reason string, ttl time.Duration) error {
+	b := ban{CIDR: ipCIDR(ip), Reason: reason, Created: time.Now()}
+	if ttl > 0 {
+		b.Expires = b.Created.Add(ttl)
+	}
+	if err := l.db.addBan(b); err != nil {
+		return err
+	}
+	l.mu.Lock()
+	l.ips[b.CIDR] = b
+	l.mu.Unlock()
+	l.bus.publish(event{Type: eventBan, IP: ip, Reason: reason})
+	return nil
+}
+
+// banned reports whether ip is banned, either by itself or as part of a
+// banned network.
+func (l *banList) banned(ip net.IP) bool {
+	now := time.Now()
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if b, ok := l.ips[ipCIDR(ip)]; ok && !b.expired(now) {
+		return true
+	}
+	for _, n := range l.nets {
+		if n.net.Contains(ip) && !n.expired(now) {
+			return true
+		}
+	}
+	return false
+}
+
+// cidrs returns the CIDRs of all bans that haven't expired, sorted.
+func (l *banList) cidrs() []string {
+	now := time.Now()
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	cidrs := []string{}
+	for c, b := range l.ips {
+		if !b.expired(now) {
+			cidrs = append(cidrs, c)
+		}
+	}
+	for _, n := range l.nets {
+		if !n.expired(now) {
+			cidrs = append(cidrs, n.CIDR)
+		}
+	}
+	slices.Sort(cidrs)
+	return cidrs
+}
+
+// connCallback drops connections of banned sources.
+func (l *banList) connCallback(_ ssh.Context, conn net.Conn) net.Conn {
+	ip := remoteIP(conn.RemoteAddr())
+	if ip == nil || !l.banned(ip) {
+		return conn
+	}
+	bansEnforced.Inc()
+	return nil
+}
+
+// middleware rejects sessions of sources that were banned after their
+// connection was accepted.
+func (l *banList) middleware() wish.Middleware {
+	return func(next ssh.Handler) ssh.Handler {
+		return func(sess ssh.Session) {
+			if !l.banned(remoteIP(sess.RemoteAddr())) {
+				next(sess)
+				return
+			}
+			bansEnforced.Inc()
+			getSessionStats(sess).setOutcome("banned")
+			wish.Errorln(sess, "You're banned, bozo.")
+		}
+	}
+}
+
+// sync deletes expired bans and picks up bans changed by other processes.
+// It never returns.
+func (l *banList) sync() {
+	for range time.Tick(10 * time.Second) {
+		if err := l.db.deleteExpiredBans(time.Now()); err != nil {
+			log.Error("Could not delete expired bans", "error", err)
+		}
+		if err := l.reload(); err != nil {
+			log.Error("Could not reload bans", "error", err)
+		}
+	}
+}
+
+// autoBanConfig configures banning sources that reconnect too often.
+type autoBanConfig struct {
+	// Threshold is the number of connections within Window after which a
+	// source is banned, 0 disables automatic bans.
+	Threshold int      `json:"threshold"`
+	Window    duration `json:"window"`
+	// TTL is how long automatic bans last.
+	TTL duration `json:"ttl"`
+}
+
+type reconnects struct {
+	since time.Time
+	count int
+}
+
+// autoBanner bans sources that reconnect faster than any human would.
+type autoBanner struct {
+	cfg  autoBanConfig
+	bans *banList
+
+	mu      sync.Mutex
+	sources map[string]*reconnects
+}
+
+func newAutoBanner(cfg autoBanConfig, bans *banList) *autoBanner {
+	return &autoBanner{cfg: cfg, bans: bans, sources: make(map[string]*reconnects)}
+}
+
+// connCallback counts the connection and bans its source if it is over the
+// threshold. The connection that trips the threshold is dropped already.
+func (a *autoBanner) connCallback(_ ssh.Context, conn net.Conn) net.Conn {
+	ip := remoteIP(conn.RemoteAddr())
+	if a.cfg.Threshold <= 0 || ip == nil {
+		return conn
+	}
+	now := time.Now()
+	a.mu.Lock()
+	src, ok := a.sources[ip.String()]
+	if !ok || now.Sub(src.since) > time.Duration(a.cfg.Window) {
+		src = &reconnects{since: now}
+		a.sources[ip.String()] = src
+	}
+	src.count++
+	trip := src.count > a.cfg.Threshold
+	if trip {
+		delete(a.sources, ip.String())
+	}
+	a.mu.Unlock()
+
+	if !trip {
+		return conn
+	}
+	if err := a.bans.banIP(ip, "reconnecting too fast", time.Duration(a.cfg.TTL)); err != nil {
+		log.Error("Could not ban source", "remote", ip, "error", err)
+	}
+	return nil
+}
+
+// cleanup forgets sources whose window has passed. It never returns.
+func (a *autoBanner) cleanup() {
+	for range time.Tick(time.Minute) {
+		now := time.Now()
+		a.mu.Lock()
+		for ip, src := range a.sources {
+			if now.Sub(src.since) > time.Duration(a.cfg.Window) {
+				delete(a.sources, ip)
+			}
+		}
+		a.mu.Unlock()
+	}
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"bytes"
+	"sync"
+)
+
+// maxPooledBuffer is the largest buffer put back into the pool, a huge window
+// shouldn't keep its buffers around forever.
+const maxPooledBuffer = 1 << 20
+
+// renderBuffers are the buffers the frames are built in, shared by all
+// sessions. The result is copied out as a string, so a buffer can be reused
+// as soon as it is put back.
+var renderBuffers = sync.Pool{New: func() any { return new(bytes.Buffer) }}
+
+func getBuffer() *bytes.Buffer {
+	return renderBuffers.Get().(*bytes.Buffer)
+}
+
+func putBuffer(b *bytes.Buffer) {
+	if b.Cap() > maxPooledBuffer {
+		return
+	}
+	b.Reset()
+	renderBuffers.Put(b)
+}
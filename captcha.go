@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// captchaConfig configures the challenge visitors have to pass before they
+// get to see the banner, which keeps out bots that don't read the screen.
+type captchaConfig struct {
+	Enabled bool `json:"enabled"`
+	// Timeout is how long visitors have to answer.
+	Timeout duration `json:"timeout"`
+	// Attempts is how many wrong answers they get.
+	Attempts int `json:"attempts"`
+}
+
+// captchaWords are the words visitors may have to type.
+var captchaWords = []string{"bozo", "pwned", "banana", "toaster", "hacker", "terminal", "penguin", "firewall"}
+
+// captcha is the challenge while it is shown.
+type captcha struct {
+	question string
+	answer   string
+	input    textinput.Model
+	deadline time.Time
+	attempts int
+	wrong    bool
+}
+
+// newCaptcha asks to type a word or to do a sum, whichever comes up.
+func newCaptcha(cfg captchaConfig) *captcha {
+	c := &captcha{deadline: time.Now().Add(time.Duration(cfg.Timeout)), attempts: cfg.Attempts}
+	if rand.IntN(2) == 0 {
+		word := captchaWords[rand.IntN(len(captchaWords))]
+		c.question = fmt.Sprintf("Type %q to prove you're a human bozo:", word)
+		c.answer = word
+	} else {
+		a, b := rand.IntN(10)+1, rand.IntN(10)+1
+		c.question = fmt.Sprintf("What is %d + %d, bozo?", a, b)
+		c.answer = strconv.Itoa(a + b)
+	}
+	c.input = textinput.New()
+	c.input.CharLimit = 16
+	c.input.Focus()
+	return c
+}
+
+// updateCaptcha checks the answer on enter. Whoever runs out of attempts is
+// disconnected and reported as abuse.
+func (m model) updateCaptcha(msg tea.KeyMsg) (model, tea.Cmd) {
+	c := m.captcha
+	switch msg.String() {
+	case "ctrl+c":
+		return m, tea.Quit
+	case "enter":
+		if strings.EqualFold(strings.TrimSpace(c.input.Value()), c.answer) {
+			m.captcha = nil
+			return m, nil
+		}
+		if c.attempts <= 1 {
+			return m.failCaptcha("captcha_failed")
+		}
+		c.attempts--
+		c.wrong = true
+		c.input.Reset()
+		return m, nil
+	}
+	var cmd tea.Cmd
+	c.input, cmd = c.input.Update(msg)
+	return m, cmd
+}
+
+// stepCaptcha disconnects visitors that didn't answer in time.
+func (m model) stepCaptcha() (model, tea.Cmd) {
+	if m.captcha == nil || time.Now().Before(m.captcha.deadline) {
+		return m, nil
+	}
+	return m.failCaptcha("captcha_timeout")
+}
+
+func (m model) failCaptcha(reason string) (model, tea.Cmd) {
+	e := m.event
+	e.Type = eventAbuse
+	e.Time = time.Now()
+	e.Reason = reason
+	m.bus.publish(e)
+	return m, tea.Quit
+}
+
+func (m model) captchaView() string {
+	c := m.captcha
+	help := fmt.Sprintf("%v left", time.Until(c.deadline).Round(time.Second))
+	if c.wrong {
+		help = fmt.Sprintf("Wrong, %d attempts left, %s", c.attempts, help)
+	}
+	return m.lolcat("Are you a bot?\n") + "\n" + m.txtStyle.Render(c.question) + "\n" +
+		c.input.View() + "\n" + m.quitStyle.Render(help) + "\n"
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"golang.org/x/time/rate"
+)
+
+// chatConfig configures the chat room visitors share.
+type chatConfig struct {
+	// Rate is the sustained messages per second a source IP may post.
+	Rate  float64 `json:"rate"`
+	Burst int     `json:"burst"`
+	// MaxLength is the maximum length of a message in characters.
+	MaxLength int `json:"max_length"`
+	// History is the number of messages newcomers get to see.
+	History int `json:"history"`
+	// Censored are the words that are starred out of messages.
+	Censored []string `json:"censored"`
+}
+
+var chatMessages = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "bozo_chat_messages_total",
+	Help: "Messages posted to the chat room.",
+})
+
+var (
+	errChatEmpty   = errors.New("say something, bozo")
+	errChatTooFast = errors.New("slow down, bozo")
+)
+
+// chatMessage is a message posted to the chat room.
+type chatMessage struct {
+	Time   time.Time
+	Author string
+	Text   string
+}
+
+type chatMsg chatMessage
+
+type chatErrMsg struct{ err error }
+
+// chatHub is the chat room. It keeps the recent messages and sends every new
+// one to all sessions.
+type chatHub struct {
+	cfg      chatConfig
+	sessions *sessionRegistry
+	censored *regexp.Regexp
+	// salt makes the names of the authors unguessable from their IPs, it
+	// changes with every start.
+	salt [16]byte
+
+	mu       sync.Mutex
+	history  []chatMessage
+	limiters map[string]*rate.Limiter
+}
+
+func newChatHub(cfg chatConfig, sessions *sessionRegistry) *chatHub {
+	h := &chatHub{cfg: cfg, sessions: sessions, limiters: make(map[string]*rate.Limiter)}
+	rand.Read(h.salt[:])
+	if len(cfg.Censored) > 0 {
+		words := make([]string, len(cfg.Censored))
+		for i, w := range cfg.Censored {
+			words[i] = regexp.QuoteMeta(w)
+		}
+		h.censored = regexp.MustCompile(`(?i)` + strings.Join(words, "|"))
+	}
+	return h
+}
+
+// author is the anonymous name of ip in the chat room.
+func (h *chatHub) author(ip net.IP) string {
+	sum := sha256.Sum256(append(h.salt[:], ip.String()...))
+	return "bozo-" + hex.EncodeToString(sum[:3])
+}
+
+// clean strips control characters, so nobody gets to send escape sequences
+// to the other terminals, and stars out the censored words.
+func (h *chatHub) clean(text string) string {
+	text = sanitize(text, h.cfg.MaxLength)
+	if h.censored != nil {
+		text = h.censored.ReplaceAllStringFunc(text, func(w string) string {
+			return strings.Repeat("*", utf8.RuneCountInString(w))
+		})
+	}
+	return text
+}
+
+// sanitize strips the control characters visitors could mess with the
+// terminals of others with, and cuts text to max runes.
+func sanitize(text string, max int) string {
+	text = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, text)
+	text = strings.TrimSpace(text)
+	if utf8.RuneCountInString(text) > max {
+		text = string([]rune(text)[:max])
+	}
+	return text
+}
+
+// allow takes a token from the bucket of ip.
+func (h *chatHub) allow(ip net.IP) bool {
+	// Forget the sources that would have a full bucket anyway.
+	if len(h.limiters) > 1024 {
+		for k, l := range h.limiters {
+			if l.Tokens() >= float64(h.cfg.Burst) {
+				delete(h.limiters, k)
+			}
+		}
+	}
+	l, ok := h.limiters[ip.String()]
+	if !ok {
+		l = rate.NewLimiter(rate.Limit(h.cfg.Rate), h.cfg.Burst)
+		h.limiters[ip.String()] = l
+	}
+	return l.Allow()
+}
+
+// post posts text as ip and sends it to all sessions.
+func (h *chatHub) post(ip net.IP, text string) error {
+	text = h.clean(text)
+	if text == "" {
+		return errChatEmpty
+	}
+	h.mu.Lock()
+	if !h.allow(ip) {
+		h.mu.Unlock()
+		return errChatTooFast
+	}
+	msg := chatMessage{Time: time.Now(), Author: h.author(ip), Text: text}
+	h.history = append(h.history, msg)
+	if len(h.history) > h.cfg.History {
+		h.history = h.history[len(h.history)-h.cfg.History:]
+	}
+	h.mu.Unlock()
+	chatMessages.Inc()
+	h.sessions.broadcast(chatMsg(msg))
+	return nil
+}
+
+// recent returns the messages newcomers get to see.
+func (h *chatHub) recent() []chatMessage {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]chatMessage(nil), h.history...)
+}
+
+// typingPage is a page taking text input. While it is typing, it gets all
+// keys except the ones switching pages.
+type typingPage interface {
+	typing() bool
+}
+
+// typing reports whether the page shown is taking text input.
+func (m model) typing() bool {
+	p, ok := m.pages[m.page].(typingPage)
+	return ok && p.typing()
+}
+
+// chatHeight is the number of lines of the chat page around the messages.
+const chatHeight = 6
+
+// chatPage is the chat room.
+type chatPage struct {
+	input    textinput.Model
+	messages []chatMessage
+	err      error
+}
+
+func (chatPage) title() string { return "Chat" }
+func (chatPage) name() string  { return "chat" }
+
+func (p chatPage) typing() bool { return p.input.Focused() }
+
+func (p chatPage) open(m model) (page, tea.Cmd) {
+	p.input = textinput.New()
+	p.input.Placeholder = "say something"
+	p.input.CharLimit = m.chat.cfg.MaxLength
+	p.input.Width = max(m.width-4, 1)
+	p.messages = m.chat.recent()
+	p.err = nil
+	return p, p.input.Focus()
+}
+
+func (p chatPage) update(m model, msg tea.Msg) (page, tea.Cmd) {
+	switch msg := msg.(type) {
+	case chatMsg:
+		p.messages = append(p.messages, chatMessage(msg))
+		if len(p.messages) > m.chat.cfg.History {
+			p.messages = p.messages[len(p.messages)-m.chat.cfg.History:]
+		}
+		return p, nil
+	case chatErrMsg:
+		p.err = msg.err
+		return p, nil
+	case tea.WindowSizeMsg:
+		p.input.Width = max(m.width-4, 1)
+		return p, nil
+	case tea.KeyMsg:
+		switch key := msg.String(); {
+		case key == "esc":
+			p.input.Blur()
+			return p, nil
+		case !p.input.Focused():
+			if key == "enter" || key == "i" {
+				return p, p.input.Focus()
+			}
+			return p, nil
+		case key == "enter":
+			text := p.input.Value()
+			p.input.Reset()
+			p.err = nil
+			hub, ip := m.chat, remoteIP(m.address)
+			return p, func() tea.Msg {
+				if err := hub.post(ip, text); err != nil {
+					return chatErrMsg{err}
+				}
+				return nil
+			}
+		}
+	}
+	var cmd tea.Cmd
+	p.input, cmd = p.input.Update(msg)
+	return p, cmd
+}
+
+func (p chatPage) view(m model) string {
+	me := m.chat.author(remoteIP(m.address))
+	lines := make([]string, 0, len(p.messages))
+	for _, msg := range p.messages {
+		author := msg.Author
+		if author == me {
+			author += " (you)"
+		}
+		lines = append(lines, fmt.Sprintf("%s %s: %s", msg.Time.Format("15:04"), author, msg.Text))
+	}
+	if len(lines) == 0 {
+		lines = append(lines, "Nobody said anything yet.")
+	}
+	lines = lines[max(len(lines)-max(m.height-chatHeight, 1), 0):]
+	status := "enter to send, esc to stop typing"
+	if !p.input.Focused() {
+		status = "i to type"
+	}
+	if p.err != nil {
+		status = p.err.Error()
+	}
+	return m.lolcat("Chat\n") + "\n" + m.txtStyle.Render(strings.Join(lines, "\n")) + "\n" +
+		p.input.View() + "\n" + m.quitStyle.Render(status) + "\n"
+}
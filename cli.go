@@ -0,0 +1,347 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"slices"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	gossh "golang.org/x/crypto/ssh"
+)
+
+const commandUsage = `Commands:
+  stats top [-by ips|asns|countries] [-n count]
+  ban add [-reason text] [-ttl duration] <ip|cidr>
+  ban remove <ip|cidr>
+  ban list
+  hostkey rotate <ed25519|ecdsa|rsa>
+  guestbook list [-all] [-n count]
+  guestbook approve <id>
+  guestbook hide <id>
+`
+
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "Usage: %s [flags] [command]\n\n", os.Args[0])
+	fmt.Fprintln(out, "Without a command the ssh server is started.")
+	fmt.Fprintln(out)
+	fmt.Fprint(out, commandUsage)
+	fmt.Fprintln(out, "\nFlags:")
+	flag.PrintDefaults()
+}
+
+// cli runs the admin commands, either from the command line or for an admin
+// connected over ssh.
+type cli struct {
+	cfg config
+	// actor identifies who ran the commands in the audit log.
+	actor  string
+	stdout io.Writer
+	stderr io.Writer
+	// hostKeys are the keys of the running server, if the commands run in
+	// it.
+	hostKeys *hostKeys
+}
+
+// runCommand runs the command given on the command line and returns the exit
+// code.
+func runCommand(cfg config, args []string) int {
+	c := cli{cfg: cfg, actor: cliActor(), stdout: os.Stdout, stderr: os.Stderr}
+	return c.run(args)
+}
+
+// run runs the command in args and returns the exit code.
+func (c cli) run(args []string) int {
+	switch {
+	case len(args) >= 2 && args[0] == "stats" && args[1] == "top":
+		return c.statsTop(args[2:])
+	case len(args) >= 2 && args[0] == "ban" && args[1] == "add":
+		return c.banAdd(args[2:])
+	case len(args) >= 2 && args[0] == "ban" && args[1] == "remove":
+		return c.banRemove(args[2:])
+	case len(args) >= 2 && args[0] == "ban" && args[1] == "list":
+		return c.banList()
+	case len(args) >= 2 && args[0] == "hostkey" && args[1] == "rotate":
+		return c.hostKeyRotate(args[2:])
+	case len(args) >= 2 && args[0] == "guestbook" && args[1] == "list":
+		return c.guestbookList(args[2:])
+	case len(args) >= 2 && args[0] == "guestbook" && (args[1] == "approve" || args[1] == "hide"):
+		return c.guestbookModerate(args[1], args[2:])
+	default:
+		fmt.Fprintf(c.stderr, "unknown command %q\n\n%s", args, commandUsage)
+		return 2
+	}
+}
+
+// flagSet returns a flag set for the command name writing its errors to
+// c.stderr.
+func (c cli) flagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(c.stderr)
+	return fs
+}
+
+func (c cli) statsTop(args []string) int {
+	fs := c.flagSet("stats top")
+	by := fs.String("by", string(byIP), "group visits by ips, asns or countries")
+	n := fs.Int("n", 10, "number of entries to show")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	db, err := openStore(c.cfg.Database)
+	if err != nil {
+		fmt.Fprintln(c.stderr, "could not open database:", err)
+		return 1
+	}
+	defer db.close()
+	entries, err := db.top(leaderboardKind(*by), *n)
+	if err != nil {
+		fmt.Fprintln(c.stderr, err)
+		return 1
+	}
+	w := tabwriter.NewWriter(c.stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "#\tNAME\tVISITS")
+	for i, e := range entries {
+		fmt.Fprintf(w, "%d\t%s\t%d\n", i+1, e.Name, e.Visits)
+	}
+	if err := w.Flush(); err != nil {
+		return 1
+	}
+	return 0
+}
+
+// cliActor identifies the local user in the audit log.
+func cliActor() string {
+	if u, err := user.Current(); err == nil {
+		return "cli:" + u.Username
+	}
+	return "cli"
+}
+
+// openStoreAndAudit opens what the commands changing the server state need.
+func (c cli) openStoreAndAudit() (*store, *auditLog, bool) {
+	db, err := openStore(c.cfg.Database)
+	if err != nil {
+		fmt.Fprintln(c.stderr, "could not open database:", err)
+		return nil, nil, false
+	}
+	audit, err := openAuditLog(c.cfg.AuditLog)
+	if err != nil {
+		db.close()
+		fmt.Fprintln(c.stderr, "could not open audit log:", err)
+		return nil, nil, false
+	}
+	return db, audit, true
+}
+
+func (c cli) banAdd(args []string) int {
+	fs := c.flagSet("ban add")
+	reason := fs.String("reason", "banned by operator", "why the source is banned")
+	ttl := fs.Duration("ttl", 0, "how long the ban lasts, 0 bans forever")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprintln(c.stderr, "usage: ban add [-reason text] [-ttl duration] <ip|cidr>")
+		return 2
+	}
+	n, err := parseCIDR(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintln(c.stderr, err)
+		return 2
+	}
+	db, audit, ok := c.openStoreAndAudit()
+	if !ok {
+		return 1
+	}
+	defer db.close()
+	b := ban{CIDR: n.String(), Reason: *reason, Created: time.Now()}
+	if *ttl > 0 {
+		b.Expires = b.Created.Add(*ttl)
+	}
+	if err := db.addBan(b); err != nil {
+		fmt.Fprintln(c.stderr, "could not add ban:", err)
+		return 1
+	}
+	if err := audit.record(c.actor, "ban-add", b.CIDR); err != nil {
+		fmt.Fprintln(c.stderr, "could not write audit log:", err)
+		return 1
+	}
+	fmt.Fprintln(c.stdout, "Banned", b.CIDR)
+	return 0
+}
+
+func (c cli) banRemove(args []string) int {
+	if len(args) != 1 {
+		fmt.Fprintln(c.stderr, "usage: ban remove <ip|cidr>")
+		return 2
+	}
+	n, err := parseCIDR(args[0])
+	if err != nil {
+		fmt.Fprintln(c.stderr, err)
+		return 2
+	}
+	db, audit, ok := c.openStoreAndAudit()
+	if !ok {
+		return 1
+	}
+	defer db.close()
+	removed, err := db.removeBan(n.String())
+	if err != nil {
+		fmt.Fprintln(c.stderr, "could not remove ban:", err)
+		return 1
+	}
+	if !removed {
+		fmt.Fprintln(c.stderr, n.String(), "is not banned")
+		return 1
+	}
+	if err := audit.record(c.actor, "ban-remove", n.String()); err != nil {
+		fmt.Fprintln(c.stderr, "could not write audit log:", err)
+		return 1
+	}
+	fmt.Fprintln(c.stdout, "Unbanned", n.String())
+	return 0
+}
+
+func (c cli) banList() int {
+	db, err := openStore(c.cfg.Database)
+	if err != nil {
+		fmt.Fprintln(c.stderr, "could not open database:", err)
+		return 1
+	}
+	defer db.close()
+	bans, err := db.bans(time.Now())
+	if err != nil {
+		fmt.Fprintln(c.stderr, err)
+		return 1
+	}
+	w := tabwriter.NewWriter(c.stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CIDR\tREASON\tCREATED\tEXPIRES")
+	for _, b := range bans {
+		expires := "never"
+		if !b.Expires.IsZero() {
+			expires = b.Expires.Format(time.DateTime)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", b.CIDR, b.Reason, b.Created.Format(time.DateTime), expires)
+	}
+	if err := w.Flush(); err != nil {
+		return 1
+	}
+	return 0
+}
+
+func (c cli) hostKeyRotate(args []string) int {
+	if len(args) != 1 {
+		fmt.Fprintln(c.stderr, "usage: hostkey rotate <ed25519|ecdsa|rsa>")
+		return 2
+	}
+	i := slices.IndexFunc(c.cfg.HostKeys, func(k hostKeyConfig) bool { return k.Type == args[0] })
+	if i < 0 {
+		fmt.Fprintf(c.stderr, "no %s host key configured\n", args[0])
+		return 2
+	}
+	audit, err := openAuditLog(c.cfg.AuditLog)
+	if err != nil {
+		fmt.Fprintln(c.stderr, "could not open audit log:", err)
+		return 1
+	}
+	old, rotated, err := rotateHostKey(c.cfg.HostKeys[i])
+	if err != nil {
+		fmt.Fprintln(c.stderr, "could not rotate host key:", err)
+		return 1
+	}
+	if err := audit.record(c.actor, "hostkey-rotate", c.cfg.HostKeys[i].Path); err != nil {
+		fmt.Fprintln(c.stderr, "could not write audit log:", err)
+		return 1
+	}
+	fmt.Fprintln(c.stdout, "Old:", gossh.FingerprintSHA256(old))
+	fmt.Fprintln(c.stdout, "New:", gossh.FingerprintSHA256(rotated))
+	if c.hostKeys == nil {
+		fmt.Fprintln(c.stdout, "Send SIGHUP to the server to apply it.")
+		return 0
+	}
+	if err := c.hostKeys.reload(c.cfg.HostKeys); err != nil {
+		fmt.Fprintln(c.stderr, err)
+		return 1
+	}
+	fmt.Fprintln(c.stdout, "New connections get the new key.")
+	return 0
+}
+
+func (c cli) guestbookList(args []string) int {
+	fs := c.flagSet("guestbook list")
+	all := fs.Bool("all", false, "include flagged and hidden entries")
+	n := fs.Int("n", 20, "number of entries to show")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	db, err := openStore(c.cfg.Database)
+	if err != nil {
+		fmt.Fprintln(c.stderr, "could not open database:", err)
+		return 1
+	}
+	defer db.close()
+	entries, err := db.guestbook(*n, *all)
+	if err != nil {
+		fmt.Fprintln(c.stderr, err)
+		return 1
+	}
+	w := tabwriter.NewWriter(c.stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tTIME\tIP\tNAME\tSTATE\tMESSAGE")
+	for _, e := range entries {
+		state := "shown"
+		switch {
+		case e.Hidden:
+			state = "hidden"
+		case e.Flagged:
+			state = "flagged"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\n", e.ID, e.Time.Format(time.DateTime), e.IP, e.Name, state, e.Message)
+	}
+	if err := w.Flush(); err != nil {
+		return 1
+	}
+	return 0
+}
+
+// guestbookModerate approves or hides a guestbook entry.
+func (c cli) guestbookModerate(action string, args []string) int {
+	if len(args) != 1 {
+		fmt.Fprintf(c.stderr, "usage: guestbook %s <id>\n", action)
+		return 2
+	}
+	id, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		fmt.Fprintf(c.stderr, "invalid id %q\n", args[0])
+		return 2
+	}
+	db, audit, ok := c.openStoreAndAudit()
+	if !ok {
+		return 1
+	}
+	defer db.close()
+	found, err := db.moderateGuestbook(id, false, action == "hide")
+	if err != nil {
+		fmt.Fprintln(c.stderr, "could not moderate guestbook:", err)
+		return 1
+	}
+	if !found {
+		fmt.Fprintln(c.stderr, "no guestbook entry", id)
+		return 1
+	}
+	if err := audit.record(c.actor, "guestbook-"+action, args[0]); err != nil {
+		fmt.Fprintln(c.stderr, "could not write audit log:", err)
+		return 1
+	}
+	if action == "hide" {
+		fmt.Fprintln(c.stdout, "Hid entry", id)
+	} else {
+		fmt.Fprintln(c.stdout, "Approved entry", id)
+	}
+	return 0
+}
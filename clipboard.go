@@ -0,0 +1,40 @@
+package main
+
+import (
+	"encoding/base64"
+	"io"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// osc52 is the escape sequence asking the terminal to put text into the
+// clipboard. Terminals that don't support it ignore it.
+func osc52(text string) string {
+	return "\x1b]52;c;" + base64.StdEncoding.EncodeToString([]byte(text)) + "\a"
+}
+
+// copiedFor is how long the banner tells that something was copied.
+const copiedFor = 3 * time.Second
+
+type copiedMsg time.Time
+
+// copyToClipboard sends text to the clipboard of the visitor.
+func (m model) copyToClipboard(text string) tea.Cmd {
+	out := m.out
+	return func() tea.Msg {
+		if _, err := io.WriteString(out, osc52(text)); err != nil {
+			return nil
+		}
+		return copiedMsg(time.Now())
+	}
+}
+
+// clipboardText is what the y key copies, the visitor's IP unless another
+// payload is configured.
+func (m model) clipboardText() string {
+	if m.clipboard != "" {
+		return m.clipboard
+	}
+	return remoteIP(m.address).String()
+}
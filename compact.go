@@ -0,0 +1,35 @@
+package main
+
+import "fmt"
+
+// Windows narrower or lower than this get the compact layout, the banner
+// would wrap into garbage on them.
+const (
+	compactWidth  = 40
+	compactHeight = 12
+)
+
+// smallBanner is the banner graphic of the compact layout.
+const smallBanner = "⣠⠾⠛⠷⣄ get pwned, bozo\n"
+
+// compact reports whether the window is too small for the usual layout. The
+// size isn't known before the first resize, that one isn't compact.
+func (m model) compact() bool {
+	return m.width > 0 && m.height > 0 && (m.width < compactWidth || m.height < compactHeight)
+}
+
+// compactView is the banner page of the compact layout, just the logo and
+// the IP of the visitor.
+func (m model) compactView() string {
+	return m.renderBanner() + m.txtStyle.Render(fmt.Sprintf(translate(m.lang, "Your IP is %v"), remoteIP(m.address))) + "\n"
+}
+
+// compactTabBar is the tab bar of the compact layout, only the page shown and
+// the key switching to the next one.
+func (m model) compactTabBar() string {
+	bar := fmt.Sprintf("%d/%d %s", m.page+1, len(m.pages), m.pages[m.page].title())
+	if next := m.keys.first("next_page"); next != "" {
+		bar += ", " + next
+	}
+	return m.quitStyle.Render(bar)
+}
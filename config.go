@@ -0,0 +1,403 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"time"
+)
+
+// config holds the settings that can be changed without recompiling. It is
+// read from a JSON file, fields missing from the file keep their defaults.
+type config struct {
+	// Listeners are the addresses the server listens on.
+	Listeners []listenerConfig `json:"listeners"`
+	// RunAs is the user the server switches to after binding its listeners.
+	RunAs runAsConfig `json:"run_as"`
+	// Sandbox restricts what the process may do once it is up.
+	Sandbox sandboxConfig `json:"sandbox"`
+	// Admission turns away new connections while the server is overloaded.
+	Admission admissionConfig `json:"admission"`
+	// DrainTimeout is how long active sessions get to wrap up on shutdown.
+	DrainTimeout duration `json:"drain_timeout"`
+	// LogLevel is the initial log level, it can be changed at runtime with
+	// SIGUSR1 (more verbose) and SIGUSR2 (less verbose).
+	LogLevel string `json:"log_level"`
+	// AuditLog is the append-only log of administrative actions.
+	AuditLog string `json:"audit_log"`
+	// MaxSessions caps the simultaneous sessions of the whole server, 0
+	// disables the cap.
+	MaxSessions int `json:"max_sessions"`
+	// Memory turns away new sessions and closes idle ones when the process
+	// runs out of memory.
+	Memory memoryConfig `json:"memory"`
+	// MaxSessionsPerIP caps the simultaneous sessions of a single source IP,
+	// 0 disables the limit.
+	MaxSessionsPerIP int `json:"max_sessions_per_ip"`
+	// ACL decides which sources may connect at all.
+	ACL aclConfig `json:"acl"`
+	// AutoBan bans sources that reconnect too often.
+	AutoBan autoBanConfig `json:"auto_ban"`
+	// Firewall pushes bans into nftables or ipset sets.
+	Firewall firewallConfig `json:"firewall"`
+	// HostKeys are the host keys the server presents, one per type.
+	HostKeys []hostKeyConfig `json:"host_keys"`
+	// Algorithms is the policy of the offered key exchanges, ciphers and MACs.
+	Algorithms algorithmsConfig `json:"algorithms"`
+	// Auth configures admin access.
+	Auth authConfig `json:"auth"`
+	// AuthBanner is sent to clients before authentication.
+	AuthBanner authBannerConfig `json:"auth_banner"`
+	// Scanners drops sources that keep connecting without looking at the
+	// banner.
+	Scanners scannerConfig `json:"scanners"`
+	// Backoff delays sources that keep coming back to abuse the server.
+	Backoff backoffConfig `json:"backoff"`
+	// RateLimit limits how fast sources can open new connections.
+	RateLimit rateLimitConfig `json:"rate_limit"`
+	// DNSBL checks visitors against DNS blocklists.
+	DNSBL dnsblConfig `json:"dnsbl"`
+	// Env configures capturing the environment variables clients send.
+	Env envConfig `json:"env"`
+	// Keepalive reaps sessions of clients that went away.
+	Keepalive keepaliveConfig `json:"keepalive"`
+	// SessionGuard caps the resources of a single session.
+	SessionGuard sessionGuardConfig `json:"session_guard"`
+	// Clipboard is what visitors get copied into their clipboard with the y
+	// key, their IP when empty.
+	Clipboard string `json:"clipboard"`
+	// InfoURL and Contact are linked in the footer of the banner, they are
+	// left out when empty. Hyperlinks is "auto" to link them if the terminal
+	// seems to support it, "always" or "never".
+	InfoURL    string `json:"info_url"`
+	Contact    string `json:"contact"`
+	Hyperlinks string `json:"hyperlinks"`
+	// Screensaver is how long a session may go without input before the
+	// banner starts bouncing around, 0 disables it.
+	Screensaver duration `json:"screensaver"`
+	// ReducedMotion is the default of the reduced motion setting, which stops
+	// the animations for visitors who get sick of them or are on a slow link.
+	ReducedMotion bool `json:"reduced_motion"`
+	// Plain starts sessions in plain mode, which describes the banner instead
+	// of drawing it, for screen readers.
+	Plain bool `json:"plain"`
+	// ExitAnimation dissolves the banner for a moment when a visitor quits.
+	ExitAnimation bool `json:"exit_animation"`
+	// Chat configures the chat room.
+	Chat chatConfig `json:"chat"`
+	// Keys remaps the keys of the banner, by action, e.g. "quit": ["q", "esc"].
+	// An action mapped to no keys is disabled.
+	Keys map[string][]string `json:"keys"`
+	// Captcha has visitors pass a challenge before they see the banner.
+	Captcha captchaConfig `json:"captcha"`
+	// Guestbook configures the guestbook.
+	Guestbook guestbookConfig `json:"guestbook"`
+	// Throttle limits the output bandwidth of each session.
+	Throttle throttleConfig `json:"throttle"`
+	// Busy slows the animations down while the server is busy.
+	Busy busyConfig `json:"busy"`
+	// VisitorsFile is where the visitor counter is persisted.
+	VisitorsFile string `json:"visitors_file"`
+	// Syslog optionally sends logs to syslog as well.
+	Syslog syslogConfig `json:"syslog"`
+	// LogFile optionally writes logs to a rotated file as well.
+	LogFile logFileConfig `json:"log_file"`
+	// MetricsAddress is where Prometheus metrics are served, e.g.
+	// "127.0.0.1:9222". Metrics are not served when empty.
+	MetricsAddress string `json:"metrics_address"`
+	// LogSampling limits how many connections per source are logged.
+	LogSampling logSamplingConfig `json:"log_sampling"`
+	// Database is the path of the SQLite database visits are recorded in.
+	Database string      `json:"database"`
+	GeoIP    geoIPConfig `json:"geoip"`
+	// ReverseDNS looks up the hostnames of visitors to show them on the
+	// banner.
+	ReverseDNS bool `json:"reverse_dns"`
+	// CountryPolicy blocks, tarpits or greets visitors depending on their
+	// country.
+	CountryPolicy countryPolicyConfig `json:"country_policy"`
+	// Fail2banLog is where auth failures and abuse are logged in a format
+	// fail2ban can parse, see contrib/fail2ban. Disabled when empty.
+	Fail2banLog string `json:"fail2ban_log"`
+	// Statsd optionally pushes metrics to a StatsD or Datadog agent.
+	Statsd statsdConfig `json:"statsd"`
+	// Discord optionally posts the connects to a Discord webhook.
+	Discord discordConfig `json:"discord"`
+	// Slack optionally posts connects, captured auths and uploads to a Slack
+	// webhook.
+	Slack slackConfig `json:"slack"`
+	// Telegram optionally messages the operator about notable visitors.
+	Telegram telegramConfig `json:"telegram"`
+	// Webhook optionally posts the events of the chosen types to any URL.
+	Webhook webhookConfig `json:"webhook"`
+	// Ntfy optionally pushes the sessions likely to be human to a phone.
+	Ntfy ntfyConfig `json:"ntfy"`
+	// Digest optionally mails a summary of the visitors every day.
+	Digest digestConfig `json:"digest"`
+}
+
+func defaultConfig() config {
+	return config{
+		Listeners: []listenerConfig{
+			{Address: net.JoinHostPort(host, port), Label: port},
+		},
+		Admission: admissionConfig{
+			HighWatermark: 200,
+			LowWatermark:  150,
+		},
+		Sandbox: sandboxConfig{
+			ReadOnly:  []string{"/etc", "/usr/share", "/dev/urandom"},
+			ReadWrite: []string{".", "/dev/null", "/tmp"},
+			// The dynamic loader and libc have to be executable as well.
+			Exec: []string{"/usr/bin", "/usr/sbin", "/bin", "/sbin", "/usr/lib", "/lib", "/lib64"},
+		},
+		DrainTimeout:     duration(30 * time.Second),
+		LogLevel:         "info",
+		AuditLog:         "audit.log",
+		MaxSessions:      3,
+		MaxSessionsPerIP: 3,
+		VisitorsFile:     "visitors.json",
+		Database:         "bozo.db",
+		ACL: aclConfig{
+			ReloadInterval: duration(30 * time.Second),
+		},
+		AutoBan: autoBanConfig{
+			Threshold: 60,
+			Window:    duration(time.Minute),
+			TTL:       duration(24 * time.Hour),
+		},
+		Firewall: firewallConfig{
+			Table:    "inet filter",
+			Set:      "bozo_bans",
+			Set6:     "bozo_bans6",
+			Interval: duration(10 * time.Second),
+		},
+		HostKeys: []hostKeyConfig{
+			{Path: ".ssh/id_ed25519", Type: "ed25519"},
+			{Path: ".ssh/id_ecdsa", Type: "ecdsa"},
+			{Path: ".ssh/id_rsa", Type: "rsa"},
+		},
+		Algorithms: algorithmsConfig{
+			Profile: "default",
+		},
+		Auth: authConfig{
+			AdminKeys:        "admin_keys",
+			TrustedCAs:       "trusted_cas",
+			AdminPrincipals:  []string{"bozo-admin"},
+			MaxAttempts:      6,
+			MaxDailyAttempts: 1000,
+			Challenge: challengeConfig{
+				Questions: []challengeQuestion{
+					{Question: "What's 2+2, bozo?", Answers: []string{"4", "four"}},
+				},
+			},
+		},
+		AuthBanner: authBannerConfig{
+			Text: "Hello {ip}, you are about to get pwned.",
+		},
+		Scanners: scannerConfig{
+			Threshold: 5,
+			Window:    duration(10 * time.Minute),
+			TTL:       duration(time.Hour),
+		},
+		Backoff: backoffConfig{
+			Delays: []duration{
+				duration(time.Second),
+				duration(5 * time.Second),
+				duration(30 * time.Second),
+				duration(2 * time.Minute),
+			},
+			HalfLife: duration(time.Hour),
+		},
+		RateLimit: rateLimitConfig{
+			Rate:        0.5,
+			Burst:       5,
+			SubnetRate:  2,
+			SubnetBurst: 20,
+			Delay:       duration(time.Second),
+			MaxDelay:    duration(30 * time.Second),
+			DropAfter:   8,
+		},
+		CountryPolicy: countryPolicyConfig{
+			TarpitInterval: duration(10 * time.Second),
+		},
+		DNSBL: dnsblConfig{
+			Action:                 "tag",
+			ThrottleBytesPerSecond: 16 << 10,
+			Timeout:                duration(2 * time.Second),
+			Wait:                   duration(200 * time.Millisecond),
+			CacheTTL:               duration(time.Hour),
+		},
+		Env: envConfig{
+			MaxVars:        32,
+			MaxValueLength: 256,
+			Expose:         []string{"LANG", "LC_ALL", "LC_MESSAGES"},
+		},
+		Keepalive: keepaliveConfig{
+			Interval:    duration(15 * time.Second),
+			CountMax:    3,
+			IdleTimeout: duration(5 * time.Minute),
+		},
+		SessionGuard: sessionGuardConfig{
+			MaxBufferedBytes: 1 << 20,
+			WriteTimeout:     duration(30 * time.Second),
+			MaxCommands:      4,
+			MaxWidth:         1000,
+			MaxHeight:        500,
+		},
+		InfoURL:       "https://github.com/pdamianik/get-pwned-bozo",
+		Hyperlinks:    "auto",
+		Screensaver:   duration(time.Minute),
+		ExitAnimation: true,
+		Chat: chatConfig{
+			Rate:      0.5,
+			Burst:     3,
+			MaxLength: 200,
+			History:   50,
+			Censored:  []string{"fuck", "shit", "cunt", "bitch", "asshole", "nigger", "faggot"},
+		},
+		Captcha: captchaConfig{
+			Timeout:  duration(30 * time.Second),
+			Attempts: 3,
+		},
+		Guestbook: guestbookConfig{
+			Interval:  duration(24 * time.Hour),
+			MaxLength: 140,
+			Flagged:   []string{"://", "www.", "fuck", "shit", "cunt", "bitch", "asshole", "nigger", "faggot"},
+		},
+		Throttle: throttleConfig{
+			BytesPerSecond: 512 << 10,
+			Burst:          64 << 10,
+		},
+		Memory: memoryConfig{
+			ShedIdle: duration(5 * time.Minute),
+		},
+		Busy: busyConfig{
+			HighSessions: 100,
+			LowSessions:  75,
+			HighCPU:      0.8,
+			LowCPU:       0.6,
+			Interval:     duration(250 * time.Millisecond),
+		},
+		Syslog: syslogConfig{
+			Facility: "daemon",
+			Tag:      "get-pwned-bozo",
+		},
+		LogFile: logFileConfig{
+			MaxSizeMB:  100,
+			MaxAgeDays: 30,
+			MaxBackups: 10,
+			Compress:   true,
+		},
+		Discord: discordConfig{
+			Batch: defaultNotifyConfig,
+		},
+		Slack: slackConfig{
+			Events: []string{"connect", "auth", "upload"},
+			Batch:  defaultNotifyConfig,
+		},
+		Telegram: telegramConfig{
+			APIURL:     "https://api.telegram.org",
+			HumanScore: 80,
+			Batch:      defaultNotifyConfig,
+		},
+		Webhook: webhookConfig{
+			Events: []string{"connect", "auth", "upload"},
+			Batch:  defaultNotifyConfig,
+		},
+		Ntfy: ntfyConfig{
+			Events:     []string{"disconnect"},
+			HumanScore: 80,
+			Priorities: map[string]int{"disconnect": 4, "upload": 4, "guestbook": 2},
+			Batch:      defaultNotifyConfig,
+		},
+		Digest: digestConfig{
+			At:           clockTime(8 * 60),
+			Top:          5,
+			NotableScore: 80,
+		},
+		Statsd: statsdConfig{
+			Prefix: "bozo.",
+			Tags:   true,
+		},
+		LogSampling: logSamplingConfig{
+			Interval: duration(time.Minute),
+			Burst:    10,
+		},
+	}
+}
+
+// loadConfig reads the config file at path. A missing file is not an error,
+// the defaults are used instead.
+func loadConfig(path string) (config, error) {
+	cfg := defaultConfig()
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return cfg, err
+	}
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return cfg, fmt.Errorf("parse %s: %w", path, err)
+	}
+	// The firewall is synchronized for as long as the server runs, which the
+	// unprivileged user isn't allowed to.
+	if cfg.RunAs.User != "" && cfg.Firewall.Backend != "" {
+		return cfg, errors.New("firewall.backend can't be used with run_as, nft and ipset need root")
+	}
+	return cfg, nil
+}
+
+// duration is a time.Duration that is written as a string like "1m30s" in the
+// config file.
+type duration time.Duration
+
+func (d duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = duration(v)
+	return nil
+}
+
+// clockTime is a time of day in minutes since midnight, written as "22:30" in
+// the config file.
+type clockTime int
+
+func (c clockTime) String() string {
+	return fmt.Sprintf("%02d:%02d", c/60, c%60)
+}
+
+func (c clockTime) MarshalJSON() ([]byte, error) {
+	return json.Marshal(c.String())
+}
+
+func (c *clockTime) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return err
+	}
+	*c = clockTime(t.Hour()*60 + t.Minute())
+	return nil
+}
+
+// clockOf returns the time of day of t.
+func clockOf(t time.Time) clockTime {
+	return clockTime(t.Hour()*60 + t.Minute())
+}
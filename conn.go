@@ -0,0 +1,40 @@
+package main
+
+import (
+	"net"
+
+	"github.com/charmbracelet/ssh"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// withConnCallbacks runs the callbacks in order for every accepted
+// connection, before the ssh handshake. A callback returning nil drops the
+// connection and the remaining callbacks are skipped.
+func withConnCallbacks(callbacks ...ssh.ConnCallback) ssh.Option {
+	return func(s *ssh.Server) error {
+		s.ConnCallback = func(ctx ssh.Context, conn net.Conn) net.Conn {
+			for _, cb := range callbacks {
+				if conn = cb(ctx, conn); conn == nil {
+					return nil
+				}
+			}
+			return conn
+		}
+		return nil
+	}
+}
+
+// withServerConfig builds the gossh.ServerConfig of every connection by
+// applying the hooks in order.
+func withServerConfig(hooks ...func(ssh.Context, *gossh.ServerConfig)) ssh.Option {
+	return func(s *ssh.Server) error {
+		s.ServerConfigCallback = func(ctx ssh.Context) *gossh.ServerConfig {
+			cfg := &gossh.ServerConfig{}
+			for _, hook := range hooks {
+				hook(ctx, cfg)
+			}
+			return cfg
+		}
+		return nil
+	}
+}
@@ -0,0 +1,9 @@
+#!/bin/sh
+# Rules dropping the sources in the sets the ban list of get-pwned-bozo is
+# pushed into with
+#   "firewall": {"backend": "ipset"}
+set -e
+ipset -exist create bozo_bans hash:net family inet
+ipset -exist create bozo_bans6 hash:net family inet6
+iptables -I INPUT -p tcp --dport 22 -m set --match-set bozo_bans src -j DROP
+ip6tables -I INPUT -p tcp --dport 22 -m set --match-set bozo_bans6 src -j DROP
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/ssh"
+)
+
+// countdownFrom is how long before the session is dropped the countdown is
+// shown.
+const countdownFrom = 5 * time.Minute
+
+const connStartKey contextKey = "connStart"
+
+// recordConnStart records when the connection was accepted, which is when
+// the max timeout starts running. It has to come after the callbacks that may
+// hold the connection back, like the server does.
+func recordConnStart(ctx ssh.Context, conn net.Conn) net.Conn {
+	ctx.SetValue(connStartKey, time.Now())
+	return conn
+}
+
+// maxDeadline is when the max timeout drops the connection of ctx, zero if
+// there is none.
+func maxDeadline(ctx ssh.Context, timeout duration) time.Time {
+	start, ok := ctx.Value(connStartKey).(time.Time)
+	if !ok || timeout <= 0 {
+		return time.Time{}
+	}
+	return start.Add(time.Duration(timeout))
+}
+
+// disconnectAt is when the session gets dropped for running too long or going
+// without input, zero if neither limit is set.
+func (m model) disconnectAt() time.Time {
+	at := m.maxDeadline
+	if m.inputTimeout > 0 {
+		idle := m.lastInput.Add(m.inputTimeout)
+		if at.IsZero() || idle.Before(at) {
+			at = idle
+		}
+	}
+	return at
+}
+
+// stepInputTimeout quits once the session went without input for too long.
+func (m model) stepInputTimeout() tea.Cmd {
+	if m.inputTimeout <= 0 || time.Since(m.lastInput) < m.inputTimeout {
+		return nil
+	}
+	m.stats.setOutcome("idle")
+	return tea.Quit
+}
+
+// countdownView warns visitors that they are about to be dropped, once it is
+// less than countdownFrom away.
+func (m model) countdownView() string {
+	at := m.disconnectAt()
+	if at.IsZero() || time.Until(at) > countdownFrom {
+		return ""
+	}
+	left := max(time.Until(at), 0).Round(time.Second)
+	return fmt.Sprintf(translate(m.lang, "Disconnecting in %d:%02d, bozo."), int(left.Minutes()), int(left.Seconds())%60)
+}
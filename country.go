@@ -0,0 +1,121 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/ssh"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// countryRule is what happens to visitors from a country.
+type countryRule struct {
+	// Action is "block" to drop connections right after accept, "tarpit" to
+	// trickle out the handshake one byte at a time, or "banner" to show
+	// Banner (or the contents of BannerFile) instead of the usual graphic.
+	Action     string `json:"action"`
+	Banner     string `json:"banner"`
+	BannerFile string `json:"banner_file"`
+}
+
+// countryPolicyConfig maps ISO country codes to rules. It needs
+// geoip.country_db to be configured.
+type countryPolicyConfig struct {
+	Rules map[string]countryRule `json:"rules"`
+	// TarpitInterval is the pause between two bytes sent to tarpitted
+	// visitors.
+	TarpitInterval duration `json:"tarpit_interval"`
+}
+
+var countryPolicyApplied = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "bozo_country_policy_total",
+	Help: "Connections a country rule was applied to.",
+}, []string{"country", "action"})
+
+const countryBannerKey contextKey = "countryBanner"
+
+type countryPolicy struct {
+	geo      *geoIP
+	rules    map[string]countryRule
+	interval time.Duration
+}
+
+func newCountryPolicy(cfg countryPolicyConfig, geo *geoIP) (*countryPolicy, error) {
+	p := &countryPolicy{geo: geo, rules: make(map[string]countryRule), interval: time.Duration(cfg.TarpitInterval)}
+	if len(cfg.Rules) > 0 && (!geo.configured() || geo.cfg.CountryDB == "") {
+		return nil, errors.New("country rules need a GeoIP country database")
+	}
+	for country, rule := range cfg.Rules {
+		switch rule.Action {
+		case "block", "tarpit":
+		case "banner":
+			if rule.BannerFile != "" {
+				b, err := os.ReadFile(rule.BannerFile)
+				if err != nil {
+					return nil, err
+				}
+				rule.Banner = string(b)
+			}
+			rule.Banner = strings.TrimRight(rule.Banner, "\n") + "\n"
+		default:
+			return nil, fmt.Errorf("unknown action %q for country %s", rule.Action, country)
+		}
+		p.rules[strings.ToUpper(country)] = rule
+	}
+	return p, nil
+}
+
+// tarpitConn writes to the underlying connection one byte at a time, so the
+// handshake of a tarpitted visitor takes ages.
+type tarpitConn struct {
+	net.Conn
+	interval time.Duration
+}
+
+func (c tarpitConn) Write(p []byte) (int, error) {
+	for i := range p {
+		time.Sleep(c.interval)
+		if _, err := c.Conn.Write(p[i : i+1]); err != nil {
+			return i, err
+		}
+	}
+	return len(p), nil
+}
+
+// connCallback applies the rule of the visitor's country, if there is one.
+// Connections accepted while the GeoIP databases are still opening wait for
+// them, they would get through as of no country otherwise.
+func (p *countryPolicy) connCallback(ctx ssh.Context, conn net.Conn) net.Conn {
+	if len(p.rules) == 0 {
+		return conn
+	}
+	p.geo.wait()
+	country := p.geo.country(remoteIP(conn.RemoteAddr()))
+	rule, ok := p.rules[country]
+	if !ok {
+		return conn
+	}
+	countryPolicyApplied.WithLabelValues(country, rule.Action).Inc()
+	switch rule.Action {
+	case "block":
+		return nil
+	case "tarpit":
+		return tarpitConn{Conn: conn, interval: p.interval}
+	default:
+		ctx.SetValue(countryBannerKey, rule.Banner)
+		return conn
+	}
+}
+
+// sessionBanner returns the graphic to show in sess.
+func sessionBanner(sess ssh.Session) string {
+	if banner, ok := sess.Context().Value(countryBannerKey).(string); ok {
+		return banner
+	}
+	return graphic
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/muesli/termenv"
+)
+
+var profileNames = map[termenv.Profile]string{
+	termenv.TrueColor: "truecolor",
+	termenv.ANSI256:   "ansi256",
+	termenv.ANSI:      "ansi",
+	termenv.Ascii:     "ascii",
+}
+
+// frameTimer measures the frames of a session for the debug overlay. View
+// can't change the model, so the model keeps a pointer to it.
+type frameTimer struct {
+	render time.Duration
+	fps    float64
+
+	sampled time.Time
+	frames  uint64
+	// last is the last frame, shown again while the client is backed up.
+	last string
+}
+
+// frame records how long rendering a frame took and updates the frame rate
+// once a second.
+func (t *frameTimer) frame(render time.Duration, frames uint64) {
+	t.render = render
+	if since := time.Since(t.sampled); since >= time.Second {
+		t.fps = float64(frames-t.frames) / since.Seconds()
+		t.sampled = time.Now()
+		t.frames = frames
+	}
+}
+
+// debugView is the overlay toggled by the D key.
+func (m model) debugView() string {
+	return m.quitStyle.Render(fmt.Sprintf(
+		"fps %.1f  render %v  window %dx%d  tick %d  profile %s",
+		m.frames.fps,
+		m.frames.render.Round(time.Microsecond),
+		m.width,
+		m.height,
+		m.tick,
+		profileNames[m.profile],
+	))
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+// digestConfig configures the daily email summing up the visitors of the
+// day before.
+type digestConfig struct {
+	// SMTP is the host:port of the mail server, disabled when empty.
+	// STARTTLS is used when the server offers it.
+	SMTP     string   `json:"smtp"`
+	Username string   `json:"username"`
+	Password string   `json:"password"`
+	From     string   `json:"from"`
+	To       []string `json:"to"`
+	// At is the local time of day the digest is sent.
+	At clockTime `json:"at"`
+	// Top is how many countries, client versions and notable sessions are
+	// listed.
+	Top int `json:"top"`
+	// NotableScore is the sessionScore from which a session is notable.
+	NotableScore int `json:"notable_score"`
+}
+
+// digest sums up the visits of a day.
+type digest struct {
+	From, Until    time.Time
+	Visits         int
+	IPs            int
+	Countries      []leaderboardEntry
+	ClientVersions []leaderboardEntry
+	Notable        []visit
+}
+
+// digest sums up the visits from from until until.
+func (s *store) digest(from, until time.Time, top, score int) (digest, error) {
+	d := digest{From: from, Until: until}
+	since, before := from.Unix(), until.Unix()
+	err := s.db.QueryRow(
+		`SELECT COUNT(*), COUNT(DISTINCT ip) FROM visits WHERE time >= ? AND time < ?`, since, before,
+	).Scan(&d.Visits, &d.IPs)
+	if err != nil {
+		return d, err
+	}
+	if d.Countries, err = s.counts(
+		`SELECT country, COUNT(*) AS n FROM visits WHERE time >= ? AND time < ? AND country != '' GROUP BY country ORDER BY n DESC LIMIT ?`,
+		since, before, top,
+	); err != nil {
+		return d, err
+	}
+	if d.ClientVersions, err = s.counts(
+		`SELECT client_version, COUNT(*) AS n FROM visits WHERE time >= ? AND time < ? GROUP BY client_version ORDER BY n DESC LIMIT ?`,
+		since, before, top,
+	); err != nil {
+		return d, err
+	}
+	rows, err := s.db.Query(
+		`SELECT time, ip, country, client_version, duration_ms, score FROM visits
+		WHERE time >= ? AND time < ? AND score >= ? ORDER BY score DESC, duration_ms DESC LIMIT ?`,
+		since, before, score, top,
+	)
+	if err != nil {
+		return d, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			v      visit
+			at, ms int64
+		)
+		if err := rows.Scan(&at, &v.IP, &v.Country, &v.ClientVersion, &ms, &v.Score); err != nil {
+			return d, err
+		}
+		v.Time, v.Duration = time.Unix(at, 0), time.Duration(ms)*time.Millisecond
+		d.Notable = append(d.Notable, v)
+	}
+	return d, rows.Err()
+}
+
+// String renders the digest as the text of the email.
+func (d digest) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d visits from %d IPs between %s and %s.\n",
+		d.Visits, d.IPs, d.From.Format(time.DateTime), d.Until.Format(time.DateTime))
+	list := func(title string, entries []leaderboardEntry) {
+		if len(entries) == 0 {
+			return
+		}
+		fmt.Fprintf(&b, "\n%s:\n", title)
+		for _, e := range entries {
+			fmt.Fprintf(&b, "  %6d  %s\n", e.Visits, e.Name)
+		}
+	}
+	list("Top countries", d.Countries)
+	list("Top client versions", d.ClientVersions)
+	if len(d.Notable) > 0 {
+		b.WriteString("\nNotable sessions:\n")
+		for _, v := range d.Notable {
+			country := v.Country
+			if country == "" {
+				country = "??"
+			}
+			fmt.Fprintf(&b, "  %s  %s (%s), score %d, stayed %v, %s\n",
+				v.Time.Format("15:04"), v.IP, country, v.Score, v.Duration.Round(time.Second), v.ClientVersion)
+		}
+	}
+	b.WriteString("\nSee you tomorrow, bozo.\n")
+	return b.String()
+}
+
+// next returns the first time after t at the time of day c.
+func (c clockTime) next(t time.Time) time.Time {
+	at := time.Date(t.Year(), t.Month(), t.Day(), int(c/60), int(c%60), 0, 0, t.Location())
+	if !at.After(t) {
+		at = time.Date(t.Year(), t.Month(), t.Day()+1, int(c/60), int(c%60), 0, 0, t.Location())
+	}
+	return at
+}
+
+// runDigest mails the digest of the last day every day at the time of cfg.
+// It never returns.
+func runDigest(db *store, cfg digestConfig) {
+	for {
+		at := cfg.At.next(time.Now())
+		time.Sleep(time.Until(at))
+		d, err := db.digest(at.AddDate(0, 0, -1), at, cfg.Top, cfg.NotableScore)
+		if err == nil {
+			err = cfg.send(d)
+		}
+		if err != nil {
+			log.Error("Could not send the digest", "error", err)
+			notificationsSent.WithLabelValues("digest", "error").Inc()
+			continue
+		}
+		log.Info("Digest sent", "visits", d.Visits, "to", cfg.To)
+		notificationsSent.WithLabelValues("digest", "sent").Inc()
+	}
+}
+
+// send mails d to the recipients of cfg.
+func (cfg digestConfig) send(d digest) error {
+	var auth smtp.Auth
+	if cfg.Username != "" {
+		host, _, err := net.SplitHostPort(cfg.SMTP)
+		if err != nil {
+			return err
+		}
+		auth = smtp.PlainAuth("", cfg.Username, cfg.Password, host)
+	}
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\n", cfg.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(cfg.To, ", "))
+	fmt.Fprintf(&msg, "Subject: get-pwned-bozo digest for %s\r\n", d.From.Format(time.DateOnly))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\nContent-Type: text/plain; charset=utf-8\r\nContent-Transfer-Encoding: 8bit\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(d.String(), "\n", "\r\n"))
+	return smtp.SendMail(cfg.SMTP, auth, cfg.From, cfg.To, []byte(msg.String()))
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// discordConfig posts the connects to a Discord channel.
+type discordConfig struct {
+	// WebhookURL is the URL of the channel's webhook, disabled when empty.
+	WebhookURL string       `json:"webhook_url"`
+	Batch      notifyConfig `json:"batch"`
+}
+
+// discordMaxContent is the longest message Discord accepts.
+const discordMaxContent = 2000
+
+// subscribeDiscordSink posts a message listing the IP, country, client
+// version and earlier visits of every visitor that connected.
+func subscribeDiscordSink(bus *eventBus, db *store, cfg discordConfig) {
+	want := func(e event) bool { return e.Type == eventConnect }
+	subscribeNotifier(bus, db, "discord", cfg.Batch, want, func(batch []notification, dropped int) error {
+		return postJSON(cfg.WebhookURL, map[string]any{
+			"username": "get-pwned-bozo",
+			"content":  discordContent(batch, dropped),
+			// Visitors pick their client version, it shouldn't ping anyone.
+			"allowed_mentions": map[string]any{"parse": []string{}},
+		})
+	})
+}
+
+func discordContent(batch []notification, dropped int) string {
+	var b strings.Builder
+	for _, n := range batch {
+		country := n.Country
+		if country == "" {
+			country = "??"
+		}
+		visit := "first visit"
+		if n.Visits > 0 {
+			visit = fmt.Sprintf("visit #%d", n.Visits+1)
+		}
+		fmt.Fprintf(&b, "New bozo **%s** (%s) `%s`, %s\n", n.IP, country, discordEscape(n.ClientVersion), visit)
+	}
+	if dropped > 0 {
+		fmt.Fprintf(&b, "…and %d more\n", dropped)
+	}
+	return truncate(b.String(), discordMaxContent)
+}
+
+// discordEscape keeps a client version from breaking out of its code span.
+func discordEscape(s string) string {
+	return strings.ReplaceAll(s, "`", "'")
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/charmbracelet/ssh"
+	"github.com/charmbracelet/wish"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"golang.org/x/time/rate"
+)
+
+// dnsblConfig configures checking visitors against DNS blocklists.
+type dnsblConfig struct {
+	// Zones are the blocklists to query, e.g. "zen.spamhaus.org". Checks are
+	// disabled when empty.
+	Zones []string `json:"zones"`
+	// Action is what happens to listed sources: "tag" only marks them in
+	// logs and events, "throttle" limits their bandwidth to
+	// ThrottleBytesPerSecond and "reject" disconnects them.
+	Action                 string `json:"action"`
+	ThrottleBytesPerSecond int    `json:"throttle_bytes_per_second"`
+	// Timeout is the timeout of a single lookup.
+	Timeout duration `json:"timeout"`
+	// Wait is how long a new session waits for a pending lookup. Lookups
+	// start right after accept, so they're usually done by the time the
+	// session starts. If not, the session goes on unchecked.
+	Wait duration `json:"wait"`
+	// CacheTTL is how long results are cached.
+	CacheTTL duration `json:"cache_ttl"`
+}
+
+var dnsblListed = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "bozo_dnsbl_listed_total",
+	Help: "Sessions from sources listed on a DNS blocklist.",
+}, []string{"zone"})
+
+type dnsblResult struct {
+	done    chan struct{}
+	zones   []string
+	expires time.Time
+}
+
+// dnsbl looks up sources on DNS blocklists in the background and caches the
+// results.
+type dnsbl struct {
+	cfg dnsblConfig
+
+	mu      sync.Mutex
+	results map[string]*dnsblResult
+}
+
+const dnsblKey contextKey = "dnsbl"
+
+func newDNSBL(cfg dnsblConfig) *dnsbl {
+	return &dnsbl{cfg: cfg, results: make(map[string]*dnsblResult)}
+}
+
+// dnsblName returns the name to query for ip in zone: the reversed octets for
+// IPv4, the reversed nibbles for IPv6.
+func dnsblName(ip net.IP, zone string) string {
+	var parts []string
+	if ip4 := ip.To4(); ip4 != nil {
+		for i := len(ip4) - 1; i >= 0; i-- {
+			parts = append(parts, fmt.Sprint(ip4[i]))
+		}
+	} else {
+		ip16 := ip.To16()
+		for i := len(ip16) - 1; i >= 0; i-- {
+			parts = append(parts, fmt.Sprintf("%x", ip16[i]&0xf), fmt.Sprintf("%x", ip16[i]>>4))
+		}
+	}
+	return strings.Join(parts, ".") + "." + zone
+}
+
+// lookup returns the result for ip, starting a lookup if there is no cached
+// one. The result is pending until its done channel is closed.
+func (d *dnsbl) lookup(ip net.IP) *dnsblResult {
+	now := time.Now()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if r, ok := d.results[ip.String()]; ok && now.Before(r.expires) {
+		return r
+	}
+	r := &dnsblResult{done: make(chan struct{}), expires: now.Add(time.Duration(d.cfg.CacheTTL))}
+	d.results[ip.String()] = r
+	go func() {
+		defer close(r.done)
+		for _, zone := range d.cfg.Zones {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Duration(d.cfg.Timeout))
+			addrs, err := net.DefaultResolver.LookupHost(ctx, dnsblName(ip, zone))
+			cancel()
+			// Blocklists answer with 127.0.0.x for listed addresses and
+			// NXDOMAIN for everything else.
+			if err == nil && len(addrs) > 0 && strings.HasPrefix(addrs[0], "127.") {
+				r.zones = append(r.zones, zone)
+			}
+		}
+	}()
+	return r
+}
+
+// connCallback starts the lookup as early as possible.
+func (d *dnsbl) connCallback(ctx ssh.Context, conn net.Conn) net.Conn {
+	if ip := remoteIP(conn.RemoteAddr()); ip != nil && len(d.cfg.Zones) > 0 {
+		ctx.SetValue(dnsblKey, d.lookup(ip))
+	}
+	return conn
+}
+
+// listedZones returns the blocklists the source of sess is listed on, waiting
+// at most wait for a pending lookup.
+func listedZones(sess ssh.Session, wait time.Duration) []string {
+	r, ok := sess.Context().Value(dnsblKey).(*dnsblResult)
+	if !ok {
+		return nil
+	}
+	select {
+	case <-r.done:
+		return r.zones
+	case <-time.After(wait):
+		return nil
+	}
+}
+
+// middleware applies the configured action to sessions of listed sources.
+func (d *dnsbl) middleware() wish.Middleware {
+	return func(next ssh.Handler) ssh.Handler {
+		return func(sess ssh.Session) {
+			zones := listedZones(sess, time.Duration(d.cfg.Wait))
+			if len(zones) == 0 {
+				next(sess)
+				return
+			}
+			for _, zone := range zones {
+				dnsblListed.WithLabelValues(zone).Inc()
+			}
+			log.Info("Source is on a DNS blocklist", "remote", sess.RemoteAddr(), "zones", zones, "action", d.cfg.Action)
+			switch d.cfg.Action {
+			case "reject":
+				getSessionStats(sess).setOutcome("dnsbl_rejected")
+				wish.Errorln(sess, "Your IP is on a blocklist, bozo. Even we have standards.")
+			case "throttle":
+				limit := max(d.cfg.ThrottleBytesPerSecond, 1)
+				next(throttledSession{Session: sess, limiter: rate.NewLimiter(rate.Limit(limit), limit)})
+			default:
+				next(sess)
+			}
+		}
+	}
+}
+
+// cleanup forgets expired results. It never returns.
+func (d *dnsbl) cleanup() {
+	for range time.Tick(time.Minute) {
+		now := time.Now()
+		d.mu.Lock()
+		for ip, r := range d.results {
+			if !now.Before(r.expires) {
+				delete(d.results, ip)
+			}
+		}
+		d.mu.Unlock()
+	}
+}
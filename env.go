@@ -0,0 +1,87 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/ssh"
+	"github.com/charmbracelet/wish"
+)
+
+// envConfig configures capturing the environment variables clients send with
+// SendEnv or SetEnv.
+type envConfig struct {
+	// MaxVars is how many variables of a session are captured, 0 disables
+	// capturing.
+	MaxVars int `json:"max_vars"`
+	// MaxValueLength truncates longer values. Names are cut to the same
+	// length.
+	MaxValueLength int `json:"max_value_length"`
+	// Expose are the variables the Bubble Tea program gets to see, e.g. to
+	// pick the language of the banner.
+	Expose []string `json:"expose"`
+}
+
+const sessionEnvKey contextKey = "sessionEnv"
+
+// envMiddleware captures the environment of the session, before the stats
+// middleware publishes the connect event carrying it.
+func envMiddleware(cfg envConfig) wish.Middleware {
+	return func(next ssh.Handler) ssh.Handler {
+		return func(sess ssh.Session) {
+			env := sess.Environ()
+			if len(env) > cfg.MaxVars {
+				env = env[:cfg.MaxVars]
+			}
+			captured := make([]string, 0, len(env))
+			for _, kv := range env {
+				key, value, _ := strings.Cut(kv, "=")
+				key, value = truncateBytes(key, cfg.MaxValueLength), truncateBytes(value, cfg.MaxValueLength)
+				captured = append(captured, strings.ToValidUTF8(key+"="+value, "?"))
+			}
+			sess.Context().SetValue(sessionEnvKey, captured)
+			next(sess)
+		}
+	}
+}
+
+// truncateBytes cuts s to at most n bytes.
+func truncateBytes(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
+
+// capturedEnv returns the environment captured for the session, as KEY=VALUE
+// pairs.
+func capturedEnv(ctx ssh.Context) []string {
+	env, _ := ctx.Value(sessionEnvKey).([]string)
+	return env
+}
+
+// exposedEnv returns the captured variables that are listed in expose.
+func exposedEnv(ctx ssh.Context, expose []string) map[string]string {
+	exposed := make(map[string]string)
+	for _, kv := range capturedEnv(ctx) {
+		key, value, _ := strings.Cut(kv, "=")
+		for _, e := range expose {
+			if key == e {
+				exposed[key] = value
+			}
+		}
+	}
+	return exposed
+}
+
+// language returns the language code of the locale set in env, e.g. "de" for
+// LANG=de_AT.UTF-8, following the precedence of setlocale.
+func language(env map[string]string) string {
+	for _, key := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if v := env[key]; v != "" {
+			lang, _, _ := strings.Cut(v, "_")
+			lang, _, _ = strings.Cut(lang, ".")
+			return strings.ToLower(lang)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+type eventType string
+
+const (
+	eventConnect    eventType = "connect"
+	eventDisconnect eventType = "disconnect"
+	eventAuth       eventType = "auth"
+	eventKeypress   eventType = "keypress"
+	eventBan        eventType = "ban"
+	// eventAbuse is published when a source trips one of the abuse limits,
+	// the Reason says which.
+	eventAbuse eventType = "abuse"
+	// eventKonami is published when a visitor enters the Konami code.
+	eventKonami eventType = "konami"
+	// eventName is published when a visitor tells their name.
+	eventName eventType = "name"
+	// eventUpload is published when a visitor tries to upload a file over
+	// sftp or scp.
+	eventUpload eventType = "upload"
+	// eventGuestbook is published when a visitor signs the guestbook.
+	eventGuestbook eventType = "guestbook"
+)
+
+// event is something that happened on the server. Which of the optional
+// fields are set depends on the type.
+type event struct {
+	Type          eventType
+	Time          time.Time
+	SessionID     string
+	IP            net.IP
+	User          string
+	ClientVersion string
+	Term          string
+	// Listener is the label of the listener the session came in on.
+	Listener string
+	// Country is the ISO country code of IP, if GeoIP is configured.
+	Country string
+	// ASN and ASNOrg describe the network of IP, if GeoIP is configured.
+	ASN    uint
+	ASNOrg string
+	// DNSBL are the DNS blocklists IP is listed on.
+	DNSBL []string
+	// Env are the environment variables the client sent, as KEY=VALUE.
+	Env []string
+
+	// Key is the pressed key of keypress events.
+	Key string
+	// Stats and Duration describe the session on disconnect events.
+	Stats    *sessionStats
+	Duration time.Duration
+	// Method and Success describe auth events. Attempts counts the auth
+	// attempts of the connection so far, Answer is what the visitor answered
+	// to the keyboard-interactive challenge.
+	Method   string
+	Success  bool
+	Attempts int
+	Answer   string
+	// Reason says why a source was banned or which limit it tripped.
+	Reason string
+	// Name is what the visitor said their name is, on name events.
+	Name string
+	// Path is the file upload events tried to write.
+	Path string
+	// Message is the entry of guestbook events, Flagged tells whether it
+	// waits for the operator to approve it. Name is who signed it.
+	Message string
+	Flagged bool
+}
+
+var (
+	eventsPublished = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "bozo_events_total",
+		Help: "Events published on the event bus.",
+	}, []string{"type"})
+	eventsDropped = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "bozo_events_dropped_total",
+		Help: "Events dropped because a sink could not keep up.",
+	}, []string{"sink"})
+)
+
+type subscriber struct {
+	name   string
+	events chan event
+}
+
+// eventBus decouples the handlers that publish events from the sinks that
+// consume them. Every sink gets its own buffered channel and goroutine, so a
+// slow sink never blocks a session, it drops events instead.
+type eventBus struct {
+	mu   sync.RWMutex
+	subs []subscriber
+}
+
+func newEventBus() *eventBus {
+	return &eventBus{}
+}
+
+// subscribe calls handle for every event published from now on. Events are
+// handled one at a time, in the order they were published.
+func (b *eventBus) subscribe(name string, buffer int, handle func(event)) {
+	sub := subscriber{name: name, events: make(chan event, buffer)}
+	go func() {
+		for e := range sub.events {
+			handle(e)
+		}
+	}()
+	b.mu.Lock()
+	b.subs = append(b.subs, sub)
+	b.mu.Unlock()
+}
+
+// publish hands e to all sinks without blocking.
+func (b *eventBus) publish(e event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	eventsPublished.WithLabelValues(string(e.Type)).Inc()
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, sub := range b.subs {
+		select {
+		case sub.events <- e:
+		default:
+			eventsDropped.WithLabelValues(sub.name).Inc()
+		}
+	}
+}
+
+// subscribeLogSink logs connects, and session stats and the server totals on
+// disconnect, as well as the other noteworthy events. Connections the sampler
+// doesn't allow are not logged at all.
+func subscribeLogSink(bus *eventBus, totals *serverStats, sampler *logSampler) {
+	suppressed := make(map[string]bool)
+	bus.subscribe("log", 256, func(e event) {
+		switch e.Type {
+		case eventConnect:
+			if !sampler.allow(e.IP) {
+				suppressed[e.SessionID] = true
+				return
+			}
+			kv := []any{
+				"remote", e.IP,
+				"user", e.User,
+				"client", e.ClientVersion,
+				"term", e.Term,
+				"listener", e.Listener,
+			}
+			if len(e.Env) > 0 {
+				kv = append(kv, "env", strings.Join(e.Env, " "))
+			}
+			log.Info("Connect", kv...)
+		case eventDisconnect:
+			if suppressed[e.SessionID] {
+				delete(suppressed, e.SessionID)
+				return
+			}
+			log.Info("Disconnect",
+				"remote", e.IP,
+				"duration", e.Duration,
+				"bytes", e.Stats.bytes.Load(),
+				"frames", e.Stats.frames.Load(),
+				"resizes", e.Stats.resizes.Load(),
+				"keys", e.Stats.keys.Load(),
+			)
+			total, unique := totals.visitors.counts()
+			log.Info("Server totals",
+				"visitors", total,
+				"unique", unique,
+				"sessions", totals.sessions.Load(),
+				"duration", time.Duration(totals.duration.Load()),
+				"bytes", totals.bytes.Load(),
+				"frames", totals.frames.Load(),
+				"resizes", totals.resizes.Load(),
+				"keys", totals.keys.Load(),
+			)
+		case eventKeypress:
+			if suppressed[e.SessionID] {
+				return
+			}
+			log.Debug("Key pressed", "remote", e.IP, "key", e.Key)
+		case eventAuth:
+			if e.Answer != "" {
+				log.Info("Auth attempt", "remote", e.IP, "user", e.User, "method", e.Method, "success", e.Success, "attempts", e.Attempts, "answer", e.Answer)
+			} else {
+				log.Info("Auth attempt", "remote", e.IP, "user", e.User, "method", e.Method, "success", e.Success, "attempts", e.Attempts)
+			}
+		case eventBan:
+			log.Info("Banned", "remote", e.IP, "reason", e.Reason)
+		case eventAbuse:
+			log.Debug("Abuse", "remote", e.IP, "reason", e.Reason)
+		case eventKonami:
+			log.Info("Konami code entered", "remote", e.IP)
+		case eventName:
+			log.Info("Visitor told their name", "remote", e.IP, "name", e.Name)
+		case eventUpload:
+			log.Info("Upload refused", "remote", e.IP, "path", e.Path)
+		}
+	})
+}
+
+// subscribeVisitorSink counts and persists visitors on connect.
+func subscribeVisitorSink(bus *eventBus, totals *serverStats) {
+	bus.subscribe("visitors", 256, func(e event) {
+		if e.Type != eventConnect {
+			return
+		}
+		totals.visit(e.IP)
+	})
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+// subscribeFail2banSink writes auth failures and abuse to w, one event per
+// line, in a format that is kept stable so fail2ban can ban the offenders at
+// the firewall:
+//
+//	2024-03-01T12:00:00Z get-pwned-bozo: auth-failure from 192.0.2.1 user="root" method=password
+//	2024-03-01T12:00:00Z get-pwned-bozo: abuse from 192.0.2.1 reason=rate_limit_drop
+//
+// The matching filter is in contrib/fail2ban.
+func subscribeFail2banSink(bus *eventBus, w io.Writer) {
+	bus.subscribe("fail2ban", 1024, func(e event) {
+		var line string
+		switch {
+		case e.Type == eventAuth && !e.Success:
+			line = fmt.Sprintf("auth-failure from %s user=%q method=%s", e.IP, e.User, e.Method)
+		case e.Type == eventAbuse:
+			line = fmt.Sprintf("abuse from %s reason=%s", e.IP, e.Reason)
+		default:
+			return
+		}
+		if _, err := fmt.Fprintf(w, "%s get-pwned-bozo: %s\n", e.Time.UTC().Format(time.RFC3339), line); err != nil {
+			log.Error("Could not write fail2ban log", "error", err)
+		}
+	})
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// farewellTicks is how long the banner takes to dissolve when a visitor quits.
+const farewellTicks = 24
+
+// quit dissolves the banner before quitting, unless the exit animation is
+// disabled or the visitor asked for reduced motion or plain mode. Pressing
+// ctrl+c while it plays quits right away.
+func (m model) quit(msg tea.KeyMsg) (model, tea.Cmd) {
+	if !m.exitAnimation || m.still() || m.leaving || msg.String() == "ctrl+c" {
+		return m, tea.Quit
+	}
+	m.leaving = true
+	m.leftAt = m.tick
+	return m, nil
+}
+
+// stepFarewell quits once the banner is gone.
+func (m model) stepFarewell() tea.Cmd {
+	if m.leaving && m.tick-m.leftAt >= farewellTicks {
+		return tea.Quit
+	}
+	return nil
+}
+
+// dissolve blanks out the given fraction of the characters of s, always the
+// same ones for the same fraction so the banner crumbles away steadily.
+func dissolve(s string, fraction float64) string {
+	b := strings.Builder{}
+	for i, c := range []rune(s) {
+		// Knuth's multiplicative hash spreads the characters evenly.
+		if c != '\n' && float64(uint32(i)*2654435761%1000)/1000 < fraction {
+			c = ' '
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+func (m model) farewellView() string {
+	fraction := float64(m.tick-m.leftAt) / farewellTicks
+	return m.lolcat(dissolve(m.currentBanner(), fraction)) + "\n" + m.txtStyle.Render("Bye, bozo!") + "\n"
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/netip"
+	"os/exec"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+// firewallConfig configures pushing bans into the system firewall, so banned
+// sources are dropped by the kernel before they cost an accept or a handshake.
+// The sets and the rules dropping their members have to be set up by the
+// admin, see contrib/firewall.
+type firewallConfig struct {
+	// Backend is "nftables" or "ipset", the firewall is left alone when empty.
+	Backend string `json:"backend"`
+	// Table is the nftables table containing the sets, e.g. "inet filter".
+	Table string `json:"table"`
+	// Set and Set6 are the names of the sets for IPv4 and IPv6 bans.
+	Set  string `json:"set"`
+	Set6 string `json:"set6"`
+	// Interval is how often the sets are synchronized with the ban list.
+	Interval duration `json:"interval"`
+}
+
+// firewall keeps the firewall sets in sync with the ban list.
+type firewall struct {
+	cfg  firewallConfig
+	bans *banList
+	// synced are the CIDRs in the sets after the last sync.
+	synced []string
+	wake   chan struct{}
+}
+
+func newFirewall(cfg firewallConfig, bans *banList, bus *eventBus) (*firewall, error) {
+	switch cfg.Backend {
+	case "nftables", "ipset":
+	default:
+		return nil, fmt.Errorf("unknown firewall backend %q", cfg.Backend)
+	}
+	f := &firewall{cfg: cfg, bans: bans, wake: make(chan struct{}, 1)}
+	// New bans are pushed right away, expired and removed ones on the next
+	// interval.
+	bus.subscribe("firewall", 16, func(e event) {
+		if e.Type != eventBan {
+			return
+		}
+		select {
+		case f.wake <- struct{}{}:
+		default:
+		}
+	})
+	return f, nil
+}
+
+// run synchronizes the sets whenever a source is banned and every interval.
+// It never returns.
+func (f *firewall) run() {
+	tick := time.NewTicker(time.Duration(f.cfg.Interval))
+	for {
+		if err := f.sync(); err != nil {
+			log.Error("Could not synchronize firewall", "backend", f.cfg.Backend, "error", err)
+		}
+		select {
+		case <-tick.C:
+		case <-f.wake:
+		}
+	}
+}
+
+// sync replaces the contents of the sets with the current bans, unless they
+// haven't changed since the last sync.
+func (f *firewall) sync() error {
+	cidrs := f.bans.cidrs()
+	if f.synced != nil && slices.Equal(cidrs, f.synced) {
+		return nil
+	}
+	var v4, v6 []string
+	for _, c := range uncovered(cidrs) {
+		if strings.Contains(c, ":") {
+			v6 = append(v6, c)
+		} else {
+			v4 = append(v4, c)
+		}
+	}
+	var script bytes.Buffer
+	var cmd *exec.Cmd
+	switch f.cfg.Backend {
+	case "nftables":
+		nftScript(&script, f.cfg.Table, f.cfg.Set, v4)
+		nftScript(&script, f.cfg.Table, f.cfg.Set6, v6)
+		cmd = exec.Command("nft", "-f", "-")
+	case "ipset":
+		ipsetScript(&script, f.cfg.Set, "inet", v4)
+		ipsetScript(&script, f.cfg.Set6, "inet6", v6)
+		cmd = exec.Command("ipset", "-exist", "restore")
+	}
+	cmd.Stdin = &script
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%w: %s", err, bytes.TrimSpace(out))
+	}
+	log.Debug("Synchronized firewall", "backend", f.cfg.Backend, "bans", len(cidrs))
+	f.synced = cidrs
+	return nil
+}
+
+// uncovered leaves out the bans that are covered by a banned net, like an IP
+// in a banned /24. The nftables sets are interval sets, which refuse
+// overlapping elements.
+func uncovered(cidrs []string) []string {
+	prefixes := make([]netip.Prefix, len(cidrs))
+	var nets []netip.Prefix
+	for i, c := range cidrs {
+		p, err := netip.ParsePrefix(c)
+		if err != nil {
+			addr, err := netip.ParseAddr(c)
+			if err != nil {
+				continue
+			}
+			p = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		prefixes[i] = p.Masked()
+		if p.Bits() < p.Addr().BitLen() {
+			nets = append(nets, prefixes[i])
+		}
+	}
+	kept := make([]string, 0, len(cidrs))
+	for i, c := range cidrs {
+		p := prefixes[i]
+		duplicate := slices.Index(prefixes, p) < i
+		covered := slices.ContainsFunc(nets, func(n netip.Prefix) bool {
+			return n.Bits() < p.Bits() && n.Contains(p.Addr())
+		})
+		if !duplicate && !covered {
+			kept = append(kept, c)
+		}
+	}
+	return kept
+}
+
+// nftScript writes the nft commands replacing the elements of set. nft
+// applies a script atomically, so no banned source slips through in between.
+func nftScript(w *bytes.Buffer, table, set string, cidrs []string) {
+	if set == "" {
+		return
+	}
+	fmt.Fprintf(w, "flush set %s %s\n", table, set)
+	if len(cidrs) > 0 {
+		fmt.Fprintf(w, "add element %s %s { %s }\n", table, set, strings.Join(cidrs, ", "))
+	}
+}
+
+// ipsetScript writes the ipset restore commands replacing the members of set.
+// The members are filled into a temporary set which is then swapped in.
+func ipsetScript(w *bytes.Buffer, set, family string, cidrs []string) {
+	if set == "" {
+		return
+	}
+	tmp := set + "-tmp"
+	fmt.Fprintf(w, "create %s hash:net family %s\n", set, family)
+	fmt.Fprintf(w, "create %s hash:net family %s\n", tmp, family)
+	fmt.Fprintf(w, "flush %s\n", tmp)
+	for _, c := range cidrs {
+		fmt.Fprintf(w, "add %s %s\n", tmp, c)
+	}
+	fmt.Fprintf(w, "swap %s %s\n", tmp, set)
+	fmt.Fprintf(w, "destroy %s\n", tmp)
+}
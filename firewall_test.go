@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+)
+
+func TestUncovered(t *testing.T) {
+	cidrs := []string{"192.0.2.0/24", "192.0.2.7", "192.0.2.128/25", "198.51.100.1", "2001:db8::1", "2001:db8::/32", "2001:db8:1::/48"}
+	want := []string{"192.0.2.0/24", "198.51.100.1", "2001:db8::/32"}
+	if got := uncovered(cidrs); !slices.Equal(got, want) {
+		t.Errorf("uncovered(%v) = %v, want %v", cidrs, got, want)
+	}
+	// Equal nets are only kept once.
+	if got := uncovered([]string{"192.0.2.0/24", "192.0.2.1/24"}); !slices.Equal(got, []string{"192.0.2.0/24"}) {
+		t.Errorf("equal nets: %v", got)
+	}
+}
+
+func TestNftScript(t *testing.T) {
+	var b bytes.Buffer
+	nftScript(&b, "inet filter", "bozo_bans", []string{"192.0.2.0/24", "198.51.100.1"})
+	nftScript(&b, "inet filter", "bozo_bans6", nil)
+	nftScript(&b, "inet filter", "", []string{"192.0.2.1"})
+	want := "flush set inet filter bozo_bans\n" +
+		"add element inet filter bozo_bans { 192.0.2.0/24, 198.51.100.1 }\n" +
+		"flush set inet filter bozo_bans6\n"
+	if got := b.String(); got != want {
+		t.Errorf("nftScript:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestIpsetScript(t *testing.T) {
+	var b bytes.Buffer
+	ipsetScript(&b, "bozo_bans6", "inet6", []string{"2001:db8::/32"})
+	ipsetScript(&b, "", "inet", []string{"192.0.2.1"})
+	want := "create bozo_bans6 hash:net family inet6\n" +
+		"create bozo_bans6-tmp hash:net family inet6\n" +
+		"flush bozo_bans6-tmp\n" +
+		"add bozo_bans6-tmp 2001:db8::/32\n" +
+		"swap bozo_bans6-tmp bozo_bans6\n" +
+		"destroy bozo_bans6-tmp\n"
+	if got := b.String(); got != want {
+		t.Errorf("ipsetScript:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"math"
+	"sync"
+
+	"github.com/muesli/termenv"
+)
+
+// maxBannerFrames is how many colored banners are kept before they are all
+// dropped, a few megabytes. A theme goes around the hues in 360 frames per
+// profile, so that is enough for the themes most sessions use.
+const maxBannerFrames = 1024
+
+// bannerKey is what a colored banner depends on. The hue is rounded to a
+// degree, so sessions at any tick end up on the same frames.
+type bannerKey struct {
+	banner  string
+	theme   int
+	hue     int
+	profile termenv.Profile
+}
+
+// bannerFrames are the colored banners, shared by all sessions.
+var bannerFrames = struct {
+	sync.Mutex
+	frames map[bannerKey]string
+}{frames: make(map[bannerKey]string)}
+
+// cachedBanner renders the part of banner that fits the window in the theme
+// of the session, looking it up if another session rendered it already.
+func (m model) cachedBanner(banner string) string {
+	if m.plain {
+		return banner
+	}
+	banner = m.clip(banner)
+	hue := int(math.Round(m.color.Hue())) % 360
+	key := bannerKey{banner, m.theme, hue, m.profile}
+	bannerFrames.Lock()
+	frame, ok := bannerFrames.frames[key]
+	bannerFrames.Unlock()
+	if ok {
+		return frame
+	}
+	t := themes[m.theme]
+	frame = t.lolcat(banner, t.color(float64(hue)), m.profile)
+	bannerFrames.Lock()
+	if len(bannerFrames.frames) >= maxBannerFrames {
+		clear(bannerFrames.frames)
+	}
+	bannerFrames.frames[key] = frame
+	bannerFrames.Unlock()
+	return frame
+}
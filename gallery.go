@@ -0,0 +1,97 @@
+package main
+
+import (
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// swatchWidth is the width of the preview of a theme, swatchCell the width of
+// its cell in the gallery with the border and the gap to the next one.
+const (
+	swatchWidth = 10
+	swatchCell  = swatchWidth + 3
+)
+
+// swatch is the preview the themes paint, three rows show their angle too.
+var swatch = strings.Repeat(strings.Repeat("█", swatchWidth)+"\n", 2) + strings.Repeat("█", swatchWidth)
+
+// galleryPage shows a preview of every theme in a grid for the visitor to
+// pick one.
+type galleryPage struct {
+	cursor int
+}
+
+func (galleryPage) title() string { return "Themes" }
+func (galleryPage) name() string  { return "themes" }
+
+// open starts on the theme of the session.
+func (p galleryPage) open(m model) (page, tea.Cmd) {
+	p.cursor = m.theme
+	return p, nil
+}
+
+// columns is how many cells fit next to each other.
+func (galleryPage) columns(m model) int {
+	return max(min(m.width/swatchCell, len(themes)), 1)
+}
+
+func (p galleryPage) update(m model, msg tea.Msg) (page, tea.Cmd) {
+	key, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return p, nil
+	}
+	cols := p.columns(m)
+	switch key.String() {
+	case "left":
+		p.cursor = max(p.cursor-1, 0)
+	case "right":
+		p.cursor = min(p.cursor+1, len(themes)-1)
+	case "up":
+		if p.cursor >= cols {
+			p.cursor -= cols
+		}
+	case "down":
+		if p.cursor+cols < len(themes) {
+			p.cursor += cols
+		}
+	case "enter", " ":
+		prefs := m.preferences()
+		prefs.Theme = themes[p.cursor].name
+		return p, func() tea.Msg { return setPreferencesMsg(prefs) }
+	}
+	return p, nil
+}
+
+func (p galleryPage) view(m model) string {
+	cols := p.columns(m)
+	var rows, cells []string
+	for i, t := range themes {
+		cells = append(cells, p.cell(m, i, t))
+		if len(cells) == cols || i == len(themes)-1 {
+			rows = append(rows, lipgloss.JoinHorizontal(lipgloss.Top, cells...))
+			cells = nil
+		}
+	}
+	return m.lolcat("Pick a theme, bozo\n") + "\n" + lipgloss.JoinVertical(lipgloss.Left, rows...) + "\n" +
+		m.quitStyle.Render("arrows to choose, enter to pick") + "\n"
+}
+
+// cell renders the swatch of t with its name below, the cell of the cursor
+// has a thick border and the one of the current theme is marked.
+func (p galleryPage) cell(m model, i int, t theme) string {
+	name := t.name
+	if i == m.theme {
+		name = "* " + name
+	}
+	preview := t.lolcat(swatch, t.color(m.color.Hue()), m.profile)
+	if m.plain {
+		preview = ""
+	}
+	border := m.style.Border(lipgloss.RoundedBorder()).Width(swatchWidth).MarginRight(1)
+	if i == p.cursor {
+		border = border.Border(lipgloss.ThickBorder()).BorderForeground(noireColorToLipglossColor(t.color(m.color.Hue())))
+	}
+	return border.Render(strings.TrimPrefix(preview+"\n"+m.txtStyle.Render(name), "\n"))
+}
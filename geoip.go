@@ -0,0 +1,115 @@
+package main
+
+import (
+	"net"
+	"os"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/oschwald/geoip2-golang"
+)
+
+// geoIPConfig points to MaxMind GeoLite2/GeoIP2 databases. Lookups of a
+// database that isn't configured return empty results.
+type geoIPConfig struct {
+	CountryDB string `json:"country_db"`
+	ASNDB     string `json:"asn_db"`
+}
+
+// geoIP looks up where visitors come from. The databases are opened in the
+// background, so a large one doesn't hold up the listeners. Until they are,
+// lookups return empty results, policies that can't do without the country
+// wait for them.
+type geoIP struct {
+	cfg       geoIPConfig
+	ready     chan struct{}
+	countries *geoip2.Reader
+	asns      *geoip2.Reader
+}
+
+// openGeoIP starts opening the databases of cfg. Only whether they exist is
+// checked right away, a database that fails to open is fatal once it does.
+func openGeoIP(cfg geoIPConfig) (*geoIP, error) {
+	for _, path := range []string{cfg.CountryDB, cfg.ASNDB} {
+		if path == "" {
+			continue
+		}
+		if _, err := os.Stat(path); err != nil {
+			return nil, err
+		}
+	}
+	g := &geoIP{cfg: cfg, ready: make(chan struct{})}
+	go g.open()
+	return g, nil
+}
+
+func (g *geoIP) open() {
+	defer close(g.ready)
+	if !g.configured() {
+		return
+	}
+	start := time.Now()
+	var err error
+	if g.cfg.CountryDB != "" {
+		if g.countries, err = geoip2.Open(g.cfg.CountryDB); err != nil {
+			log.Fatal("Could not open GeoIP database", "path", g.cfg.CountryDB, "error", err)
+		}
+	}
+	if g.cfg.ASNDB != "" {
+		if g.asns, err = geoip2.Open(g.cfg.ASNDB); err != nil {
+			log.Fatal("Could not open GeoIP database", "path", g.cfg.ASNDB, "error", err)
+		}
+	}
+	log.Info("Opened GeoIP databases", "took", time.Since(start))
+}
+
+// configured reports whether any database is configured, it may still be
+// opening.
+func (g *geoIP) configured() bool {
+	return g != nil && (g.cfg.CountryDB != "" || g.cfg.ASNDB != "")
+}
+
+// loaded reports whether the databases are done opening.
+func (g *geoIP) loaded() bool {
+	if g == nil {
+		return false
+	}
+	select {
+	case <-g.ready:
+		return true
+	default:
+		return false
+	}
+}
+
+// wait blocks until the databases are done opening.
+func (g *geoIP) wait() {
+	if g != nil {
+		<-g.ready
+	}
+}
+
+// country returns the ISO 3166-1 country code of ip, or "" if unknown.
+func (g *geoIP) country(ip net.IP) string {
+	if !g.loaded() || g.countries == nil || ip == nil {
+		return ""
+	}
+	c, err := g.countries.Country(ip)
+	if err != nil {
+		return ""
+	}
+	return c.Country.IsoCode
+}
+
+// asn returns the autonomous system number and organization of ip, or 0 if
+// unknown.
+func (g *geoIP) asn(ip net.IP) (uint, string) {
+	if !g.loaded() || g.asns == nil || ip == nil {
+		return 0, ""
+	}
+	a, err := g.asns.ASN(ip)
+	if err != nil {
+		return 0, ""
+	}
+	return a.AutonomousSystemNumber, a.AutonomousSystemOrganization
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/muesli/termenv"
+	"github.com/teacat/noire"
+)
+
+// maxGradients is how many gradients are kept before they are all dropped.
+// Sessions on the same tick share theirs, so this is about the number of
+// ticks, themes and profiles in use at once.
+const maxGradients = 256
+
+// gradientKey is what the colors of a frame depend on.
+type gradientKey struct {
+	theme   theme
+	color   noire.Color
+	profile termenv.Profile
+}
+
+// gradientTable holds the foreground sequence of every line and column of a
+// frame. It is never changed once it is shared.
+type gradientTable [][]string
+
+// gradients are the tables of the recent frames, shared by all sessions.
+var gradients = struct {
+	sync.Mutex
+	tables map[gradientKey]gradientTable
+}{tables: make(map[gradientKey]gradientTable)}
+
+// table returns the table to color msg starting at initialColor, computing
+// it only if no session needed one as large for the same frame yet.
+func (t theme) table(msg string, initialColor noire.Color, profile termenv.Profile) gradientTable {
+	rows, cols := 0, 0
+	for _, line := range strings.Split(msg, "\n") {
+		rows++
+		cols = max(cols, utf8.RuneCountInString(line))
+	}
+	key := gradientKey{t, initialColor, profile}
+	gradients.Lock()
+	g, ok := gradients.tables[key]
+	gradients.Unlock()
+	if ok && len(g) >= rows && (rows == 0 || len(g[0]) >= cols) {
+		return g
+	}
+	if ok {
+		rows, cols = max(rows, len(g)), max(cols, len(g[0]))
+	}
+	g = t.newGradient(rows, cols, initialColor, profile)
+	gradients.Lock()
+	if len(gradients.tables) >= maxGradients {
+		clear(gradients.tables)
+	}
+	gradients.tables[key] = g
+	gradients.Unlock()
+	return g
+}
+
+// newGradient computes the table, the hue moves by angle from one line to
+// the next and by gradient from one column to the next.
+func (t theme) newGradient(rows, cols int, initialColor noire.Color, profile termenv.Profile) gradientTable {
+	g := make(gradientTable, rows)
+	rowColor := initialColor
+	for r := range g {
+		g[r] = make([]string, cols)
+		c := rowColor
+		for i := range g[r] {
+			g[r][i] = foreground(c, profile)
+			c = c.AdjustHue(t.gradient)
+		}
+		rowColor = rowColor.AdjustHue(t.angle)
+	}
+	return g
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/log"
+	"github.com/charmbracelet/ssh"
+	"github.com/charmbracelet/wish"
+)
+
+// sessionGuardConfig caps the resources a single session can tie up.
+type sessionGuardConfig struct {
+	// MaxBufferedBytes is how much output may be waiting for a client that
+	// doesn't read, 0 disables the cap.
+	MaxBufferedBytes int64 `json:"max_buffered_bytes"`
+	// WriteTimeout is how long a single write may block, 0 disables it.
+	WriteTimeout duration `json:"write_timeout"`
+	// MaxCommands is the number of Bubble Tea commands, e.g. database
+	// lookups, that may run at once, 0 disables the cap.
+	MaxCommands int32 `json:"max_commands"`
+	// MaxWidth and MaxHeight clamp the window size clients report.
+	MaxWidth  int `json:"max_width"`
+	MaxHeight int `json:"max_height"`
+}
+
+const sessionGuardKey contextKey = "sessionGuard"
+
+var (
+	errOutputStalled = errors.New("client stopped reading")
+	errTooBusy       = errors.New("too busy right now, try again in a moment")
+)
+
+// sessionGuard enforces the caps of one session.
+type sessionGuard struct {
+	cfg      sessionGuardConfig
+	buffered atomic.Int64
+	commands atomic.Int32
+}
+
+// guardedSession closes the session when too much output is waiting for the
+// client or a write blocks for too long.
+type guardedSession struct {
+	ssh.Session
+	guard *sessionGuard
+}
+
+func (s guardedSession) Write(p []byte) (int, error) {
+	cfg := s.guard.cfg
+	defer s.guard.buffered.Add(-int64(len(p)))
+	if b := s.guard.buffered.Add(int64(len(p))); cfg.MaxBufferedBytes > 0 && b > cfg.MaxBufferedBytes {
+		s.stalled("buffered output")
+		return 0, errOutputStalled
+	}
+	if cfg.WriteTimeout > 0 {
+		t := time.AfterFunc(time.Duration(cfg.WriteTimeout), func() { s.stalled("write timeout") })
+		defer t.Stop()
+	}
+	return s.Session.Write(p)
+}
+
+func (s guardedSession) stalled(reason string) {
+	log.Debug("Closing stalled session", "remote", s.RemoteAddr(), "reason", reason)
+	if st := getSessionStats(s); st != nil {
+		st.setOutcome("stalled")
+	}
+	s.Close()
+}
+
+// cmd runs c unless the session already runs too many commands, then busy is
+// the message instead.
+func (g *sessionGuard) cmd(c tea.Cmd, busy tea.Msg) tea.Cmd {
+	if g == nil || c == nil {
+		return c
+	}
+	if n := g.commands.Add(1); g.cfg.MaxCommands > 0 && n > g.cfg.MaxCommands {
+		g.commands.Add(-1)
+		if busy == nil {
+			return nil
+		}
+		return func() tea.Msg { return busy }
+	}
+	return func() tea.Msg {
+		defer g.commands.Add(-1)
+		return c()
+	}
+}
+
+// clamp limits a window size reported by the client to sane values.
+func (g *sessionGuard) clamp(width, height int) (int, int) {
+	if g == nil {
+		return width, height
+	}
+	if g.cfg.MaxWidth > 0 {
+		width = min(width, g.cfg.MaxWidth)
+	}
+	if g.cfg.MaxHeight > 0 {
+		height = min(height, g.cfg.MaxHeight)
+	}
+	return max(width, 0), max(height, 0)
+}
+
+// getSessionGuard returns the guard of the session, or nil if the session is
+// not guarded.
+func getSessionGuard(s ssh.Session) *sessionGuard {
+	g, _ := s.Context().Value(sessionGuardKey).(*sessionGuard)
+	return g
+}
+
+// guardMiddleware enforces the per-session resource caps.
+func guardMiddleware(cfg sessionGuardConfig) wish.Middleware {
+	return func(next ssh.Handler) ssh.Handler {
+		return func(sess ssh.Session) {
+			g := &sessionGuard{cfg: cfg}
+			sess.Context().SetValue(sessionGuardKey, g)
+			next(guardedSession{Session: sess, guard: g})
+		}
+	}
+}
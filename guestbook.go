@@ -0,0 +1,297 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/paginator"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/log"
+)
+
+// guestbookConfig configures the guestbook visitors sign.
+type guestbookConfig struct {
+	// Interval is how long a source IP has to wait between two entries.
+	Interval duration `json:"interval"`
+	// MaxLength is the maximum length of an entry in characters.
+	MaxLength int `json:"max_length"`
+	// Flagged are the words that hold an entry back until the operator
+	// approves it. With Moderate all entries are held back.
+	Flagged  []string `json:"flagged"`
+	Moderate bool     `json:"moderate"`
+}
+
+var (
+	errGuestbookEmpty   = errors.New("write something, bozo")
+	errGuestbookTooSoon = errors.New("you already signed, bozo")
+)
+
+// guestbookEntry is a signed entry of the guestbook. Flagged entries wait for
+// the operator to approve them, hidden ones were removed by the operator.
+type guestbookEntry struct {
+	ID      int64
+	Time    time.Time
+	IP      string
+	Name    string
+	Message string
+	Flagged bool
+	Hidden  bool
+}
+
+// signGuestbook adds e to the guestbook, unless its IP signed it within
+// interval. The check is part of the insert, so concurrent sessions of an IP
+// can't both sign.
+func (s *store) signGuestbook(e guestbookEntry, interval time.Duration) error {
+	res, err := s.db.Exec(
+		`INSERT INTO guestbook (time, ip, name, message, flagged, hidden)
+		SELECT ?, ?, ?, ?, ?, 0
+		WHERE NOT EXISTS (SELECT 1 FROM guestbook WHERE ip = ? AND time > ?)`,
+		e.Time.Unix(), e.IP, e.Name, e.Message, e.Flagged,
+		e.IP, e.Time.Add(-interval).Unix(),
+	)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return errGuestbookTooSoon
+	}
+	return nil
+}
+
+// guestbook returns the limit latest entries, newest first. Without all only
+// the ones that are neither flagged nor hidden are returned.
+func (s *store) guestbook(limit int, all bool) ([]guestbookEntry, error) {
+	query := `SELECT id, time, ip, name, message, flagged, hidden FROM guestbook WHERE flagged = 0 AND hidden = 0 ORDER BY id DESC LIMIT ?`
+	if all {
+		query = `SELECT id, time, ip, name, message, flagged, hidden FROM guestbook ORDER BY id DESC LIMIT ?`
+	}
+	rows, err := s.db.Query(query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var entries []guestbookEntry
+	for rows.Next() {
+		var (
+			e      guestbookEntry
+			signed int64
+		)
+		if err := rows.Scan(&e.ID, &signed, &e.IP, &e.Name, &e.Message, &e.Flagged, &e.Hidden); err != nil {
+			return nil, err
+		}
+		e.Time = time.Unix(signed, 0)
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// moderateGuestbook sets the flags of the entry id, it reports whether the
+// entry exists.
+func (s *store) moderateGuestbook(id int64, flagged, hidden bool) (bool, error) {
+	res, err := s.db.Exec(`UPDATE guestbook SET flagged = ?, hidden = ? WHERE id = ?`, flagged, hidden, id)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// flagged reports whether message has to wait for the operator to approve it.
+func (c guestbookConfig) flagged(message string) bool {
+	if c.Moderate {
+		return true
+	}
+	message = strings.ToLower(message)
+	for _, w := range c.Flagged {
+		if strings.Contains(message, strings.ToLower(w)) {
+			return true
+		}
+	}
+	return false
+}
+
+// guestbookSize is how many entries the guestbook page pages through.
+const guestbookSize = 100
+
+type guestbookMsg struct {
+	entries []guestbookEntry
+	err     error
+}
+
+// signedMsg tells the guestbook page how signing went.
+type signedMsg struct {
+	flagged bool
+	err     error
+	// unsent is the message if it was turned away before signing, it is put
+	// back into the input.
+	unsent string
+}
+
+func loadGuestbook(db *store) tea.Cmd {
+	return func() tea.Msg {
+		entries, err := db.guestbook(guestbookSize, false)
+		return guestbookMsg{entries, err}
+	}
+}
+
+// signGuestbook signs the guestbook as the visitor, under their name if they
+// told it or their chat name otherwise.
+func (m model) signGuestbook(message string) tea.Cmd {
+	ip := remoteIP(m.address)
+	name := m.name
+	if name == "" {
+		name = m.chat.author(ip)
+	}
+	e := guestbookEntry{
+		Time:    time.Now(),
+		IP:      ip.String(),
+		Name:    name,
+		Message: sanitize(message, m.guestbook.MaxLength),
+	}
+	e.Flagged = m.guestbook.flagged(e.Message)
+	db, interval, bus, signed := m.db, time.Duration(m.guestbook.Interval), m.bus, m.event
+	signed.Type = eventGuestbook
+	signed.Country = m.country
+	signed.Name, signed.Message, signed.Flagged = e.Name, e.Message, e.Flagged
+	return func() tea.Msg {
+		if e.Message == "" {
+			return signedMsg{err: errGuestbookEmpty}
+		}
+		if err := db.signGuestbook(e, interval); err != nil {
+			if !errors.Is(err, errGuestbookTooSoon) {
+				log.Error("Could not sign guestbook", "error", err)
+			}
+			return signedMsg{err: err}
+		}
+		log.Info("Guestbook signed", "remote", e.IP, "name", e.Name, "flagged", e.Flagged)
+		signed.Time = e.Time
+		bus.publish(signed)
+		return signedMsg{flagged: e.Flagged}
+	}
+}
+
+// guestbookHeight is the number of lines of the guestbook page around the
+// entries.
+const guestbookHeight = 7
+
+// guestbookKeys page through the guestbook, h and l are taken by hacking back
+// and the leaderboard.
+var guestbookKeys = paginator.KeyMap{
+	PrevPage: key.NewBinding(key.WithKeys("pgup", "left")),
+	NextPage: key.NewBinding(key.WithKeys("pgdown", "right")),
+}
+
+// guestbookPage pages through the latest approved entries of the guestbook
+// and lets the visitor sign it.
+type guestbookPage struct {
+	input   textinput.Model
+	pages   paginator.Model
+	entries []guestbookEntry
+	err     error
+	status  string
+}
+
+func (guestbookPage) title() string { return "Guestbook" }
+func (guestbookPage) name() string  { return "guestbook" }
+
+func (p guestbookPage) typing() bool { return p.input.Focused() }
+
+func (p guestbookPage) open(m model) (page, tea.Cmd) {
+	p.input = textinput.New()
+	p.input.Placeholder = "leave a message"
+	p.input.CharLimit = m.guestbook.MaxLength
+	p.input.Width = max(m.width-4, 1)
+	p.pages = paginator.New()
+	p.pages.Type = paginator.Dots
+	p.pages.KeyMap = guestbookKeys
+	p.pages.ActiveDot = m.txtStyle.Render("•")
+	p.pages.InactiveDot = m.quitStyle.Render("•")
+	p.pages.PerPage = max(m.height-guestbookHeight, 1)
+	p.err = nil
+	p.status = ""
+	return p, m.guard.cmd(loadGuestbook(m.db), guestbookMsg{err: errTooBusy})
+}
+
+func (p guestbookPage) update(m model, msg tea.Msg) (page, tea.Cmd) {
+	switch msg := msg.(type) {
+	case guestbookMsg:
+		p.entries, p.err = msg.entries, msg.err
+		p.pages.SetTotalPages(len(p.entries))
+		return p, nil
+	case signedMsg:
+		if msg.unsent != "" {
+			p.input.SetValue(msg.unsent)
+		}
+		switch {
+		case msg.err != nil:
+			p.status = msg.err.Error()
+		case msg.flagged:
+			p.status = "Thanks, bozo. Your entry shows up once it is approved."
+		default:
+			p.status = "Thanks for signing, bozo."
+		}
+		if msg.err != nil || msg.flagged {
+			return p, nil
+		}
+		return p, m.guard.cmd(loadGuestbook(m.db), guestbookMsg{err: errTooBusy})
+	case tea.WindowSizeMsg:
+		p.input.Width = max(m.width-4, 1)
+		p.pages.PerPage = max(m.height-guestbookHeight, 1)
+		p.pages.SetTotalPages(len(p.entries))
+		p.pages.Page = min(p.pages.Page, max(p.pages.TotalPages-1, 0))
+		return p, nil
+	case tea.KeyMsg:
+		switch key := msg.String(); {
+		case key == "esc":
+			p.input.Blur()
+			return p, nil
+		case !p.input.Focused():
+			if key == "enter" || key == "i" {
+				p.status = ""
+				return p, p.input.Focus()
+			}
+			var cmd tea.Cmd
+			p.pages, cmd = p.pages.Update(msg)
+			return p, cmd
+		case key == "enter":
+			text := p.input.Value()
+			p.input.Reset()
+			p.input.Blur()
+			return p, m.guard.cmd(m.signGuestbook(text), signedMsg{err: errTooBusy, unsent: text})
+		}
+	}
+	var cmd tea.Cmd
+	p.input, cmd = p.input.Update(msg)
+	return p, cmd
+}
+
+func (p guestbookPage) view(m model) string {
+	var lines []string
+	switch {
+	case p.err != nil:
+		lines = append(lines, "Could not load the guestbook: "+p.err.Error())
+	case len(p.entries) == 0:
+		lines = append(lines, "Nobody signed yet, be the first bozo.")
+	}
+	start, end := p.pages.GetSliceBounds(len(p.entries))
+	for _, e := range p.entries[start:end] {
+		lines = append(lines, fmt.Sprintf("%s %s: %s", e.Time.Format(time.DateOnly), e.Name, e.Message))
+	}
+	status := p.status
+	if status == "" {
+		status = "enter to sign, esc to stop typing"
+		if !p.input.Focused() {
+			status = "i to sign, left/right to turn the pages"
+		}
+	}
+	return m.lolcat("Guestbook\n") + "\n" + m.txtStyle.Render(strings.Join(lines, "\n")) + "\n" +
+		p.pages.View() + "\n" + p.input.View() + "\n" + m.quitStyle.Render(status) + "\n"
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/progress"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// hackAfter is how long visitors look at the banner before they get hacked
+// back.
+const hackAfter = 10 * time.Second
+
+// hackStages are the steps of hacking a visitor back, each takes ticks ticks.
+var hackStages = []struct {
+	label string
+	ticks uint
+}{
+	{"Scanning your ports", 36},
+	{"Cracking your password", 48},
+	{"Exfiltrating cat pictures", 60},
+	{"Uploading your browser history", 42},
+	{"Mining crypto on your toaster", 36},
+}
+
+// hack is the joke sequence pretending to hack the visitor back.
+type hack struct {
+	bar progress.Model
+	// start is the tick the sequence started on.
+	start uint
+}
+
+func (m model) startHack() model {
+	m.hackedBack = true
+	m.hack = &hack{
+		bar:   progress.New(progress.WithDefaultGradient(), progress.WithWidth(40), progress.WithColorProfile(m.profile)),
+		start: m.tick,
+	}
+	return m
+}
+
+// done reports whether all stages are through at tick.
+func (h *hack) done(tick uint) bool {
+	elapsed := tick - h.start
+	for _, s := range hackStages {
+		if elapsed < s.ticks {
+			return false
+		}
+		elapsed -= s.ticks
+	}
+	return true
+}
+
+// updateHack handles the keys while the sequence is shown, once it is done
+// any key goes back.
+func (m model) updateHack(msg tea.KeyMsg) (model, tea.Cmd) {
+	switch {
+	case msg.String() == "ctrl+c", m.keys.matches(msg, "quit"):
+		return m.quit(msg)
+	case msg.String() == "esc":
+		m.hack = nil
+	default:
+		if m.hack.done(m.tick) {
+			m.hack = nil
+		}
+	}
+	return m, nil
+}
+
+func (m model) hackView() string {
+	b := strings.Builder{}
+	elapsed := m.tick - m.hack.start
+	for _, s := range hackStages {
+		percent := min(float64(elapsed)/float64(s.ticks), 1)
+		fmt.Fprintf(&b, "%s\n%s\n\n", m.txtStyle.Render(s.label+"..."), m.hack.bar.ViewAs(percent))
+		if elapsed < s.ticks {
+			break
+		}
+		elapsed -= s.ticks
+	}
+	help := "esc to stop hacking"
+	if m.hack.done(m.tick) {
+		b.WriteString(m.txtStyle.Render("Just kidding, bozo. Your cat pictures are safe.") + "\n")
+		help = "Press any key to go back"
+	}
+	return m.lolcat("Hacking you back\n") + "\n" + b.String() + m.quitStyle.Render(help) + "\n"
+}
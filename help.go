@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// helpExtraKeys are the keys on the help screen that can't be remapped.
+var helpExtraKeys = []struct{ key, what string }{
+	{"j/k, up/down", "scroll this help"},
+	{"click", "the banner to change its colors, or a button"},
+}
+
+// helpContent is the text of the help screen, the bound keys with the ones
+// that can't be remapped before quitting.
+func (m model) helpContent() string {
+	b := strings.Builder{}
+	b.WriteString("Keys\n")
+	for _, a := range keyActions {
+		if a.name == "quit" {
+			b.WriteString("  " + padRight(fmt.Sprintf("1-%d", m.numbered()), 14) + "switch to the page of that number\n")
+			for _, k := range helpExtraKeys {
+				b.WriteString("  " + padRight(k.key, 14) + k.what + "\n")
+			}
+		}
+		binding := m.keys[a.name]
+		if a.help == "" || a.admin && !m.admin || !binding.Enabled() {
+			continue
+		}
+		b.WriteString("  " + padRight(binding.Help().Key, 14) + binding.Help().Desc + "\n")
+	}
+	return b.String()
+}
+
+func padRight(s string, n int) string {
+	return s + strings.Repeat(" ", max(n-len(s), 1))
+}
+
+// helpHeight is the number of lines of the help page around the viewport.
+const helpHeight = 4
+
+// helpPage scrolls through the help.
+type helpPage struct {
+	viewport viewport.Model
+}
+
+func (*helpPage) title() string { return "Help" }
+func (*helpPage) name() string  { return "help" }
+
+// open sizes the help to the window.
+func (p *helpPage) open(m model) (page, tea.Cmd) {
+	vp := viewport.New(m.width, max(m.height-helpHeight, 1))
+	vp.SetContent(m.txtStyle.Render(m.helpContent()))
+	return &helpPage{viewport: vp}, nil
+}
+
+func (p *helpPage) update(m model, msg tea.Msg) (page, tea.Cmd) {
+	vp := p.viewport
+	switch msg.(type) {
+	case tea.WindowSizeMsg:
+		vp.Width = m.width
+		vp.Height = max(m.height-helpHeight, 1)
+	case tea.KeyMsg, tea.MouseMsg:
+	default:
+		return p, nil
+	}
+	var cmd tea.Cmd
+	vp, cmd = vp.Update(msg)
+	return &helpPage{viewport: vp}, cmd
+}
+
+func (p *helpPage) view(m model) string {
+	return m.lolcat("Help\n") + "\n" + p.viewport.View() + "\n"
+}
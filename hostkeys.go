@@ -0,0 +1,166 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+
+	"github.com/charmbracelet/keygen"
+	"github.com/charmbracelet/log"
+	"github.com/charmbracelet/ssh"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// hostKeyConfig is a host key the server presents. Keys that don't exist yet
+// are generated.
+type hostKeyConfig struct {
+	Path string `json:"path"`
+	// Type is "ed25519", "ecdsa" or "rsa".
+	Type string `json:"type"`
+}
+
+// loadHostKey loads the host key described by cfg, generating and writing it
+// if it doesn't exist.
+func loadHostKey(cfg hostKeyConfig) (gossh.Signer, error) {
+	kt := keygen.KeyType(cfg.Type)
+	switch kt {
+	case keygen.Ed25519, keygen.ECDSA, keygen.RSA:
+	default:
+		return nil, fmt.Errorf("unknown host key type %q", cfg.Type)
+	}
+	kp, err := keygen.New(cfg.Path, keygen.WithKeyType(kt), keygen.WithWrite())
+	if err != nil {
+		return nil, fmt.Errorf("host key %s: %w", cfg.Path, err)
+	}
+	return kp.Signer(), nil
+}
+
+// hostKey is the host key of one type. The signer can be swapped while the
+// server is running, new connections use the new key while established ones
+// are unaffected.
+type hostKey struct {
+	signer atomic.Pointer[gossh.Signer]
+}
+
+func (k *hostKey) get() gossh.Signer {
+	return *k.signer.Load()
+}
+
+func (k *hostKey) PublicKey() gossh.PublicKey {
+	return k.get().PublicKey()
+}
+
+func (k *hostKey) Sign(rand io.Reader, data []byte) (*gossh.Signature, error) {
+	return k.get().Sign(rand, data)
+}
+
+func (k *hostKey) SignWithAlgorithm(rand io.Reader, data []byte, algorithm string) (*gossh.Signature, error) {
+	s, ok := k.get().(gossh.AlgorithmSigner)
+	if !ok {
+		return k.Sign(rand, data)
+	}
+	return s.SignWithAlgorithm(rand, data, algorithm)
+}
+
+// hostKeys are the host keys of the server, one per key type.
+type hostKeys struct {
+	mu    sync.Mutex
+	types map[string]*hostKey
+}
+
+func loadHostKeys(cfgs []hostKeyConfig) (*hostKeys, error) {
+	h := &hostKeys{types: make(map[string]*hostKey)}
+	for _, cfg := range cfgs {
+		signer, err := loadHostKey(cfg)
+		if err != nil {
+			return nil, err
+		}
+		k := &hostKey{}
+		k.signer.Store(&signer)
+		h.types[signer.PublicKey().Type()] = k
+		log.Info("Loaded host key", "type", signer.PublicKey().Type(), "fingerprint", gossh.FingerprintSHA256(signer.PublicKey()))
+	}
+	return h, nil
+}
+
+// reload loads the host keys again and swaps the ones that changed. Host keys
+// of types the server didn't start with need a restart. Keys that fail to load
+// are kept, so a broken key file doesn't lock anyone out.
+func (h *hostKeys) reload(cfgs []hostKeyConfig) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var errs []error
+	for _, cfg := range cfgs {
+		signer, err := loadHostKey(cfg)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		typ := signer.PublicKey().Type()
+		k, ok := h.types[typ]
+		if !ok {
+			log.Warn("Restart to serve new host key type", "type", typ, "path", cfg.Path)
+			continue
+		}
+		old := gossh.FingerprintSHA256(k.PublicKey())
+		if fp := gossh.FingerprintSHA256(signer.PublicKey()); fp != old {
+			k.signer.Store(&signer)
+			log.Info("Rotated host key", "type", typ, "old", old, "new", fp)
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("could not reload host keys: %v", errs)
+	}
+	return nil
+}
+
+// option adds the host keys to the server, so clients that don't speak
+// ed25519 can connect as well.
+func (h *hostKeys) option() ssh.Option {
+	return func(s *ssh.Server) error {
+		for _, k := range h.types {
+			s.AddHostKey(k)
+		}
+		return nil
+	}
+}
+
+// rotateHostKey replaces the key file of cfg with a newly generated key.
+// The old key is kept next to it with an .old suffix, and put back if the new
+// one can't be generated.
+func rotateHostKey(cfg hostKeyConfig) (old, rotated gossh.PublicKey, err error) {
+	prev, err := loadHostKey(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	files := []string{cfg.Path, cfg.Path + ".pub"}
+	for _, p := range files {
+		if _, err := os.Stat(p); err != nil {
+			return nil, nil, err
+		}
+	}
+	var moved []string
+	restore := func() {
+		for _, p := range moved {
+			os.Remove(p)
+			if err := os.Rename(p+".old", p); err != nil {
+				log.Error("Could not restore host key", "path", p, "error", err)
+			}
+		}
+	}
+	for _, p := range files {
+		if err := os.Rename(p, p+".old"); err != nil {
+			restore()
+			return nil, nil, err
+		}
+		moved = append(moved, p)
+	}
+	next, err := loadHostKey(cfg)
+	if err != nil {
+		restore()
+		return nil, nil, err
+	}
+	return prev.PublicKey(), next.PublicKey(), nil
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Terminals known to support OSC8 hyperlinks, by TERM and by TERM_PROGRAM.
+var (
+	hyperlinkTerms    = []string{"xterm-kitty", "alacritty", "foot", "wezterm", "xterm-ghostty", "contour"}
+	hyperlinkPrograms = []string{"iTerm.app", "WezTerm", "vscode", "ghostty", "Hyper", "Tabby"}
+)
+
+var osc8 = regexp.MustCompile("\x1b]8;[^\x1b\a]*(?:\x1b\\\\|\a)")
+
+// supportsHyperlinks guesses from the terminal type and the environment the
+// client sent whether its terminal renders hyperlinks. The setting is "auto"
+// to guess, "always" or "never".
+func supportsHyperlinks(setting, term string, env []string) bool {
+	switch setting {
+	case "always":
+		return true
+	case "never":
+		return false
+	}
+	if slices.Contains(hyperlinkTerms, term) {
+		return true
+	}
+	for _, kv := range env {
+		k, v, _ := strings.Cut(kv, "=")
+		switch k {
+		case "TERM_PROGRAM":
+			if slices.Contains(hyperlinkPrograms, v) {
+				return true
+			}
+		case "VTE_VERSION":
+			if n, err := strconv.Atoi(v); err == nil && n >= 5000 {
+				return true
+			}
+		case "WT_SESSION":
+			return true
+		}
+	}
+	return false
+}
+
+// hyperlink links text to url, if the terminal of the session supports it.
+func (m model) hyperlink(url, text string) string {
+	if !m.hyperlinks {
+		return text
+	}
+	return "\x1b]8;;" + url + "\x1b\\" + text + "\x1b]8;;\x1b\\"
+}
+
+// stripHyperlinks removes the hyperlinks from s, keeping their text.
+func stripHyperlinks(s string) string {
+	return osc8.ReplaceAllString(s, "")
+}
+
+// visibleWidth is the width of s in cells. lipgloss counts part of the links
+// as text, so they are left out.
+func visibleWidth(s string) int {
+	return lipgloss.Width(stripHyperlinks(s))
+}
+
+// footerView greets the visitor by name and links to what this server is and
+// to its operator.
+func (m model) footerView() string {
+	var parts []string
+	if m.name != "" {
+		parts = append(parts, fmt.Sprintf(translate(m.lang, "Nice to pwn you, %s."), m.name))
+	}
+	if m.infoURL != "" {
+		parts = append(parts, translate(m.lang, "What is this?")+" "+m.hyperlink(m.infoURL, strings.TrimPrefix(m.infoURL, "https://")))
+	}
+	if m.contact != "" {
+		url := m.contact
+		if strings.Contains(url, "@") && !strings.Contains(url, ":") {
+			url = "mailto:" + url
+		}
+		parts = append(parts, translate(m.lang, "Contact:")+" "+m.hyperlink(url, m.contact))
+	}
+	return strings.Join(parts, "  ")
+}
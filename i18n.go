@@ -0,0 +1,95 @@
+package main
+
+// translations of the texts shown by the Bubble Tea program, by language. The
+// English texts are the keys.
+var translations = map[string]map[string]string{
+	"de": {
+		"Your IP is %v":                                         "Deine IP ist %v",
+		"Press '%s' for help, '%s' to quit\n":                   "Drück '%s' für Hilfe, '%s' zum Beenden\n",
+		"Press '%s' to quit\n":                                  "Drück '%s' zum Beenden\n",
+		"Server going down in %v, bozo.":                        "Server fährt in %v herunter, bozo.",
+		"Nice key, %s. We'll remember you.":                     "Schöner Key, %s. Wir merken uns dich.",
+		"Welcome back, %s! Last visit: %s.":                     "Willkommen zurück, %s! Letzter Besuch: %s.",
+		"You're the only bozo here.":                            "Du bist der einzige Bozo hier.",
+		"1 other bozo online":                                   "1 anderer Bozo online",
+		"%d other bozos online":                                 "%d andere Bozos online",
+		"Nice to pwn you, %s.":                                  "Schön, dich zu pwnen, %s.",
+		"What is this?":                                         "Was ist das?",
+		"Contact:":                                              "Kontakt:",
+		"Disconnecting in %d:%02d, bozo.":                       "Verbindung wird in %d:%02d getrennt, bozo.",
+		"Someone just connected.":                               "Gerade hat sich wer verbunden.",
+		"Someone from %s just connected.":                       "Gerade hat sich wer aus %s verbunden.",
+		"Muted, %s to hear about new bozos again.":              "Stumm, %s um wieder von neuen Bozos zu hören.",
+		"Unmuted, %s to mute again.":                            "Nicht mehr stumm, %s für stumm.",
+		"Copied to your clipboard, if your terminal lets us.\n": "In deine Zwischenablage kopiert, falls dein Terminal uns lässt.\n",
+	},
+	"fr": {
+		"Your IP is %v":                                         "Ton IP est %v",
+		"Press '%s' for help, '%s' to quit\n":                   "Appuie sur '%s' pour l'aide, '%s' pour quitter\n",
+		"Press '%s' to quit\n":                                  "Appuie sur '%s' pour quitter\n",
+		"Server going down in %v, bozo.":                        "Le serveur s'arrête dans %v, bozo.",
+		"Nice key, %s. We'll remember you.":                     "Jolie clé, %s. On se souviendra de toi.",
+		"Welcome back, %s! Last visit: %s.":                     "Re-bonjour, %s ! Dernière visite : %s.",
+		"You're the only bozo here.":                            "Tu es le seul bozo ici.",
+		"1 other bozo online":                                   "1 autre bozo en ligne",
+		"%d other bozos online":                                 "%d autres bozos en ligne",
+		"Nice to pwn you, %s.":                                  "Ravi de te pwner, %s.",
+		"What is this?":                                         "C'est quoi ?",
+		"Contact:":                                              "Contact :",
+		"Disconnecting in %d:%02d, bozo.":                       "Déconnexion dans %d:%02d, bozo.",
+		"Someone just connected.":                               "Quelqu'un vient de se connecter.",
+		"Someone from %s just connected.":                       "Quelqu'un de %s vient de se connecter.",
+		"Muted, %s to hear about new bozos again.":              "Muet, %s pour réentendre parler des nouveaux bozos.",
+		"Unmuted, %s to mute again.":                            "Plus muet, %s pour couper à nouveau.",
+		"Copied to your clipboard, if your terminal lets us.\n": "Copié dans ton presse-papiers, si ton terminal le veut bien.\n",
+	},
+	"es": {
+		"Your IP is %v":                                         "Tu IP es %v",
+		"Press '%s' for help, '%s' to quit\n":                   "Pulsa '%s' para ayuda, '%s' para salir\n",
+		"Press '%s' to quit\n":                                  "Pulsa '%s' para salir\n",
+		"Server going down in %v, bozo.":                        "El servidor se apaga en %v, bozo.",
+		"Nice key, %s. We'll remember you.":                     "Bonita clave, %s. Te recordaremos.",
+		"Welcome back, %s! Last visit: %s.":                     "¡Bienvenido de nuevo, %s! Última visita: %s.",
+		"You're the only bozo here.":                            "Eres el único bozo aquí.",
+		"1 other bozo online":                                   "1 bozo más en línea",
+		"%d other bozos online":                                 "%d bozos más en línea",
+		"Nice to pwn you, %s.":                                  "Un placer pwnearte, %s.",
+		"What is this?":                                         "¿Qué es esto?",
+		"Contact:":                                              "Contacto:",
+		"Disconnecting in %d:%02d, bozo.":                       "Desconectando en %d:%02d, bozo.",
+		"Someone just connected.":                               "Alguien acaba de conectarse.",
+		"Someone from %s just connected.":                       "Alguien de %s acaba de conectarse.",
+		"Muted, %s to hear about new bozos again.":              "Silenciado, %s para saber de nuevos bozos otra vez.",
+		"Unmuted, %s to mute again.":                            "Sin silenciar, %s para silenciar otra vez.",
+		"Copied to your clipboard, if your terminal lets us.\n": "Copiado a tu portapapeles, si tu terminal nos deja.\n",
+	},
+}
+
+// countryLanguages are the languages of the countries that speak one we have
+// translations for, by ISO country code.
+var countryLanguages = map[string]string{
+	"DE": "de", "AT": "de", "CH": "de", "LI": "de",
+	"FR": "fr", "BE": "fr", "LU": "fr", "MC": "fr",
+	"ES": "es", "MX": "es", "AR": "es", "CO": "es", "CL": "es", "PE": "es", "VE": "es",
+	"EC": "es", "GT": "es", "CU": "es", "BO": "es", "DO": "es", "HN": "es", "PY": "es",
+	"SV": "es", "NI": "es", "CR": "es", "PA": "es", "UY": "es",
+}
+
+// sessionLanguage picks the language of a session: the one of the client's
+// locale if there are translations for it, or else the one of the country of
+// the visitor. English is the fallback.
+func sessionLanguage(locale, country string) string {
+	if _, ok := translations[locale]; ok || locale == "en" {
+		return locale
+	}
+	return countryLanguages[country]
+}
+
+// translate returns msg in the given language, or msg itself if there is no
+// translation.
+func translate(lang, msg string) string {
+	if t, ok := translations[lang][msg]; ok {
+		return t
+	}
+	return msg
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/charmbracelet/ssh"
+	"github.com/charmbracelet/wish"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+var sessionsReaped = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "bozo_sessions_reaped_total",
+	Help: "Sessions closed because the client stopped answering keepalives.",
+})
+
+// keepaliveConfig configures detecting clients that went away without closing
+// their connection.
+type keepaliveConfig struct {
+	// Interval is how often a keepalive is sent to the client, 0 disables
+	// keepalives.
+	Interval duration `json:"interval"`
+	// CountMax is how many keepalives may go unanswered before the
+	// connection is closed, like ClientAliveCountMax of OpenSSH.
+	CountMax int `json:"count_max"`
+	// IdleTimeout closes connections that neither send nor receive anything,
+	// e.g. ones stuck in the handshake, 0 disables it.
+	IdleTimeout duration `json:"idle_timeout"`
+	// MaxTimeout closes connections this long after they were accepted, 0
+	// disables it.
+	MaxTimeout duration `json:"max_timeout"`
+	// InputTimeout closes sessions the visitor didn't type into for this
+	// long. Unlike the idle timeout it isn't reset by the frames of the
+	// banner. 0 disables it.
+	InputTimeout duration `json:"input_timeout"`
+}
+
+// keepalive probes the clients of all sessions and reaps the dead ones.
+type keepalive struct {
+	cfg keepaliveConfig
+}
+
+func newKeepalive(cfg keepaliveConfig) *keepalive {
+	return &keepalive{cfg: cfg}
+}
+
+// option sets the idle and max timeouts of the server.
+func (k *keepalive) option() ssh.Option {
+	return func(s *ssh.Server) error {
+		if err := wish.WithIdleTimeout(time.Duration(k.cfg.IdleTimeout))(s); err != nil {
+			return err
+		}
+		return wish.WithMaxTimeout(time.Duration(k.cfg.MaxTimeout))(s)
+	}
+}
+
+// middleware sends keepalives for as long as the session runs.
+func (k *keepalive) middleware() wish.Middleware {
+	return func(next ssh.Handler) ssh.Handler {
+		return func(sess ssh.Session) {
+			if k.cfg.Interval > 0 {
+				conn, ok := sess.Context().Value(ssh.ContextKeyConn).(gossh.Conn)
+				if ok {
+					go k.probe(sess, conn)
+				}
+			}
+			next(sess)
+		}
+	}
+}
+
+// probe sends a keepalive every interval and closes the connection once
+// CountMax of them went unanswered. A client that is still there replies
+// to the request, even if it doesn't know it.
+func (k *keepalive) probe(sess ssh.Session, conn gossh.Conn) {
+	interval := time.Duration(k.cfg.Interval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	replies := make(chan struct{}, 1)
+	missed := 0
+	pending := false
+	for {
+		select {
+		case <-sess.Context().Done():
+			return
+		case <-replies:
+			pending = false
+			missed = 0
+		case <-ticker.C:
+			if pending {
+				missed++
+				if missed >= k.cfg.CountMax {
+					log.Debug("Reaping dead session", "remote", sess.RemoteAddr(), "missed", missed)
+					sessionsReaped.Inc()
+					if st := getSessionStats(sess); st != nil {
+						st.setOutcome("reaped")
+					}
+					conn.Close()
+					return
+				}
+				continue
+			}
+			pending = true
+			go func() {
+				if _, _, err := conn.SendRequest("keepalive@openssh.com", true, nil); err == nil {
+					replies <- struct{}{}
+				}
+			}()
+		}
+	}
+}
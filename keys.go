@@ -0,0 +1,93 @@
+package main
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/log"
+)
+
+// keyAction is something a key does outside of the games and text inputs.
+// Actions without help are easter eggs and left out of the help screen.
+type keyAction struct {
+	name  string
+	keys  []string
+	help  string
+	admin bool
+}
+
+// keyActions are the actions with their default keys, in the order of the
+// help screen. The actions switching pages are named like the page.
+var keyActions = []keyAction{
+	{"next_page", []string{"tab"}, "switch to the next page", false},
+	{"prev_page", []string{"shift+tab"}, "switch to the previous page", false},
+	{"help", []string{"?"}, "show or hide this help", false},
+	{"chat", []string{"c"}, "chat with the other visitors, esc to stop typing", false},
+	{"leaderboard", []string{"l"}, "show the leaderboard", false},
+	{"guestbook", []string{"b"}, "read and sign the guestbook", false},
+	{"events", []string{"e"}, "tail what your terminal sends, j/k to scroll", false},
+	{"themes", []string{"T"}, "pick a color theme from the gallery", false},
+	{"settings", []string{"o"}, "change your settings", false},
+	{"about", []string{"a"}, "show what this server is about", false},
+	{"stats", []string{"s"}, "show the server stats", true},
+	{"hack", []string{"h"}, "get hacked back", false},
+	{"copy", []string{"y"}, "copy your IP to your clipboard", false},
+	{"theme", []string{"t"}, "switch to the next color theme", false},
+	{"motion", []string{"m"}, "stop or start the animations", false},
+	{"mute", []string{"x"}, "mute or unmute the news about new visitors", false},
+	{"plain", []string{"p"}, "plain text for screen readers, or back to the art", false},
+	{"snake", []string{"g"}, "", false},
+	{"debug", []string{"D"}, "", false},
+	{"name", []string{"n"}, "tell us your name, on the banner", false},
+	{"quit", []string{"q"}, "quit, ctrl+c always does", false},
+}
+
+// keyMap binds the actions to keys, by action name.
+type keyMap map[string]key.Binding
+
+// newKeyMap binds the actions to their default keys, unless remapped gives
+// others. An action remapped to no keys is disabled.
+func newKeyMap(remapped map[string][]string) keyMap {
+	k := make(keyMap, len(keyActions))
+	for _, a := range keyActions {
+		keys := a.keys
+		if r, ok := remapped[a.name]; ok {
+			keys = r
+		}
+		b := key.NewBinding(key.WithKeys(keys...), key.WithHelp(strings.Join(keys, ", "), a.help))
+		if len(keys) == 0 {
+			b.SetEnabled(false)
+		}
+		k[a.name] = b
+	}
+	for name := range remapped {
+		if !slices.ContainsFunc(keyActions, func(a keyAction) bool { return a.name == name }) {
+			log.Warn("Unknown key action in config", "action", name)
+		}
+	}
+	return k
+}
+
+// matches reports whether msg is one of the keys of action.
+func (k keyMap) matches(msg tea.KeyMsg, action string) bool {
+	return key.Matches(msg, k[action])
+}
+
+// first is the first key of action, empty if it is disabled.
+func (k keyMap) first(action string) string {
+	if keys := k[action].Keys(); len(keys) > 0 && k[action].Enabled() {
+		return keys[0]
+	}
+	return ""
+}
+
+// hint is the key to show for action in the hints around the screens, or
+// fallback if action is disabled.
+func (k keyMap) hint(action, fallback string) string {
+	if key := k.first(action); key != "" {
+		return key
+	}
+	return fallback
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"slices"
+	"time"
+)
+
+var konamiCode = [...]string{"up", "up", "down", "down", "left", "right", "left", "right", "b", "a"}
+
+// konamiDuration is how long the Konami banner is shown.
+const konamiDuration = 5 * time.Second
+
+const konamiBanner = `╔═══════════════════════════════╗
+║                               ║
+║      ↑ ↑ ↓ ↓ ← → ← → B A      ║
+║                               ║
+║        +30 LIVES, BOZO        ║
+║                               ║
+╚═══════════════════════════════╝
+`
+
+var konamiTheme = themes[slices.IndexFunc(themes, func(t theme) bool { return t.name == "glitch" })]
+
+// trackKonami remembers key as the last pressed one, it reports whether the
+// last keys pressed were the Konami code and starts showing its banner.
+func (m model) trackKonami(key string) (model, bool) {
+	copy(m.konami[:], m.konami[1:])
+	m.konami[len(m.konami)-1] = key
+	if m.konami != konamiCode {
+		return m, false
+	}
+	m.konami = [len(konamiCode)]string{}
+	m.konamiUntil = time.Now().Add(konamiDuration)
+	e := m.event
+	e.Type = eventKonami
+	e.Time = time.Now()
+	m.bus.publish(e)
+	return m, true
+}
+
+// konamiActive reports whether the Konami banner is shown.
+func (m model) konamiActive() bool {
+	return time.Now().Before(m.konamiUntil)
+}
+
+// currentBanner is the banner graphic shown right now.
+func (m model) currentBanner() string {
+	if m.plain {
+		return m.bannerDescription()
+	}
+	if m.compact() {
+		return smallBanner
+	}
+	if m.konamiActive() {
+		return konamiBanner
+	}
+	return m.banner
+}
+
+// renderBanner renders the banner graphic, the Konami banner glitches.
+func (m model) renderBanner() string {
+	if m.plain {
+		return m.currentBanner()
+	}
+	if m.compact() {
+		return m.cachedBanner(smallBanner)
+	}
+	if m.konamiActive() {
+		return konamiTheme.lolcat(konamiBanner, m.color, m.profile)
+	}
+	return m.cachedBanner(m.banner)
+}
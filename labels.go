@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"runtime/pprof"
+
+	"github.com/charmbracelet/ssh"
+	"github.com/charmbracelet/wish"
+)
+
+// sessionLabels tell the goroutines of a session apart in CPU profiles.
+func sessionLabels(s ssh.Session) context.Context {
+	return pprof.WithLabels(context.Background(), pprof.Labels(
+		"session", s.Context().SessionID(),
+		"remote", s.RemoteAddr().String(),
+	))
+}
+
+// pprofLabelMiddleware labels the goroutines handling a session, the ones they
+// start inherit the labels.
+func pprofLabelMiddleware() wish.Middleware {
+	return func(next ssh.Handler) ssh.Handler {
+		return func(s ssh.Session) {
+			pprof.SetGoroutineLabels(sessionLabels(s))
+			next(s)
+		}
+	}
+}
+
+// stage runs f labelled as stage of the session, for profiles to tell
+// updates from rendering.
+func (m model) stage(name string, f func()) {
+	labels := m.labels
+	if labels == nil {
+		labels = context.Background()
+	}
+	pprof.Do(labels, pprof.Labels("stage", name), func(context.Context) { f() })
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"math"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/mattn/go-runewidth"
+)
+
+// place centers view in the window. Every line is shifted by the same amount,
+// so the view moves as a whole instead of every line being centered on its
+// own.
+func (m model) place(view string) string {
+	left := strings.Repeat(" ", centerGap(m.width-visibleWidth(view)))
+	b := getBuffer()
+	defer putBuffer(b)
+	for i, l := range strings.Split(view, "\n") {
+		if i > 0 {
+			b.WriteByte('\n')
+		}
+		b.WriteString(left)
+		// Bubble Tea cuts lines by the width lipgloss counts, which would cut
+		// a link in half.
+		if m.width > 0 && len(left)+lipgloss.Width(l) > m.width {
+			l = stripHyperlinks(l)
+		}
+		b.WriteString(l)
+	}
+	return lipgloss.PlaceVertical(m.height, lipgloss.Center, b.String())
+}
+
+// offset is where place puts the top left corner of view.
+func (m model) offset(view string) (x, y int) {
+	return centerGap(m.width - visibleWidth(view)), centerGap(m.height - lipgloss.Height(view))
+}
+
+// clip cuts text to the window, so what falls outside of it isn't colored
+// only to be cut by Bubble Tea. Lines are cut on the right like Bubble Tea
+// does, rows below the window are dropped.
+func (m model) clip(text string) string {
+	if m.width <= 0 || m.height <= 0 {
+		return text
+	}
+	lines := strings.Split(text, "\n")
+	if len(lines) > m.height {
+		lines = lines[:m.height]
+	}
+	for i, l := range lines {
+		if runewidth.StringWidth(l) > m.width {
+			lines[i] = runewidth.Truncate(l, m.width, "")
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// centerGap is the part of gap put before centered content.
+func centerGap(gap int) int {
+	if gap <= 0 {
+		return 0
+	}
+	return gap - int(math.Round(float64(gap)*0.5))
+}
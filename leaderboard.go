@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// leaderboardKind is what visits are grouped by on the leaderboard.
+type leaderboardKind string
+
+const (
+	byIP      leaderboardKind = "ips"
+	byASN     leaderboardKind = "asns"
+	byCountry leaderboardKind = "countries"
+)
+
+type leaderboardEntry struct {
+	Name   string
+	Visits int
+}
+
+// top returns the limit most frequent visitor IPs, ASNs or countries.
+// Visits with an unknown ASN or country are left out.
+func (s *store) top(kind leaderboardKind, limit int) ([]leaderboardEntry, error) {
+	var query string
+	switch kind {
+	case byIP:
+		query = `SELECT ip, COUNT(*) AS n FROM visits GROUP BY ip ORDER BY n DESC LIMIT ?`
+	case byASN:
+		query = `SELECT 'AS' || asn || ' ' || MAX(asn_org), COUNT(*) AS n FROM visits WHERE asn != 0 GROUP BY asn ORDER BY n DESC LIMIT ?`
+	case byCountry:
+		query = `SELECT country, COUNT(*) AS n FROM visits WHERE country != '' GROUP BY country ORDER BY n DESC LIMIT ?`
+	default:
+		return nil, fmt.Errorf("unknown leaderboard %q", kind)
+	}
+	return s.counts(query, limit)
+}
+
+// counts runs query, which selects a name and a count.
+func (s *store) counts(query string, args ...any) ([]leaderboardEntry, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var entries []leaderboardEntry
+	for rows.Next() {
+		var e leaderboardEntry
+		if err := rows.Scan(&e.Name, &e.Visits); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// leaderboard is what the leaderboard screen shows.
+type leaderboard struct {
+	loaded    bool
+	err       error
+	ips       []leaderboardEntry
+	asns      []leaderboardEntry
+	countries []leaderboardEntry
+}
+
+type leaderboardMsg leaderboard
+
+const leaderboardSize = 5
+
+// loadLeaderboard queries the leaderboard in the background, so the database
+// never blocks a frame.
+func loadLeaderboard(db *store) tea.Cmd {
+	return func() tea.Msg {
+		l := leaderboard{loaded: true}
+		if l.ips, l.err = db.top(byIP, leaderboardSize); l.err != nil {
+			return leaderboardMsg(l)
+		}
+		if l.asns, l.err = db.top(byASN, leaderboardSize); l.err != nil {
+			return leaderboardMsg(l)
+		}
+		l.countries, l.err = db.top(byCountry, leaderboardSize)
+		return leaderboardMsg(l)
+	}
+}
+
+// leaderboardPage shows the leaderboard, it is loaded whenever the page is
+// opened.
+type leaderboardPage struct {
+	leaderboard leaderboard
+}
+
+func (*leaderboardPage) title() string { return "Leaderboard" }
+func (*leaderboardPage) name() string  { return "leaderboard" }
+
+func (p *leaderboardPage) open(m model) (page, tea.Cmd) {
+	return &leaderboardPage{}, m.guard.cmd(loadLeaderboard(m.db), leaderboardMsg{loaded: true, err: errTooBusy})
+}
+
+func (p *leaderboardPage) update(_ model, msg tea.Msg) (page, tea.Cmd) {
+	if msg, ok := msg.(leaderboardMsg); ok {
+		return &leaderboardPage{leaderboard: leaderboard(msg)}, nil
+	}
+	return p, nil
+}
+
+func (p *leaderboardPage) view(m model) string {
+	var msg string
+	switch l := p.leaderboard; {
+	case !l.loaded:
+		msg = "Loading..."
+	case l.err == errTooBusy:
+		msg = "Too busy right now, try again in a moment"
+	case l.err != nil:
+		msg = "The leaderboard got pwned, try again later"
+	default:
+		msg = formatLeaderboard("Top bozos", maskIPs(l.ips)) +
+			"\n" + formatLeaderboard("Top networks", l.asns) +
+			"\n" + formatLeaderboard("Top countries", l.countries)
+	}
+	return m.lolcat("Leaderboard\n") + "\n" + m.txtStyle.Render(msg)
+}
+
+func formatLeaderboard(title string, entries []leaderboardEntry) string {
+	b := strings.Builder{}
+	b.WriteString(title + "\n")
+	if len(entries) == 0 {
+		b.WriteString("  nobody yet\n")
+	}
+	for i, e := range entries {
+		fmt.Fprintf(&b, "  %d. %-32s %d\n", i+1, e.Name, e.Visits)
+	}
+	return b.String()
+}
+
+// maskIPs masks the IPs of the entries.
+func maskIPs(entries []leaderboardEntry) []leaderboardEntry {
+	masked := make([]leaderboardEntry, len(entries))
+	for i, e := range entries {
+		masked[i] = leaderboardEntry{Name: maskIP(e.Name), Visits: e.Visits}
+	}
+	return masked
+}
+
+// maskIP hides the host part of an address, visitors shouldn't be able to see
+// each other's IPs.
+func maskIP(s string) string {
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return s
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return fmt.Sprintf("%d.%d.x.x", ip4[0], ip4[1])
+	}
+	return ip.Mask(net.CIDRMask(32, 128)).String() + "/32"
+}
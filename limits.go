@@ -0,0 +1,76 @@
+package main
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/charmbracelet/ssh"
+	"github.com/charmbracelet/wish"
+)
+
+// perIPLimitMiddleware caps the number of simultaneous sessions of a single
+// source IP, so one bot can't exhaust the PTYs and goroutines of everyone
+// else. A max of 0 disables the limit.
+func perIPLimitMiddleware(bus *eventBus, max int) wish.Middleware {
+	var mu sync.Mutex
+	sessions := make(map[string]int)
+	return func(next ssh.Handler) ssh.Handler {
+		return func(sess ssh.Session) {
+			if max <= 0 {
+				next(sess)
+				return
+			}
+			ip := remoteIP(sess.RemoteAddr()).String()
+			mu.Lock()
+			if sessions[ip] >= max {
+				mu.Unlock()
+				getSessionStats(sess).setOutcome("ip_limited")
+				e := sessionEvent(sess)
+				e.Type = eventAbuse
+				e.Reason = "session_limit"
+				bus.publish(e)
+				wish.Errorln(sess, "Easy there, bozo. You already have enough sessions open.")
+				return
+			}
+			sessions[ip]++
+			mu.Unlock()
+
+			next(sess)
+
+			mu.Lock()
+			if sessions[ip]--; sessions[ip] <= 0 {
+				delete(sessions, ip)
+			}
+			mu.Unlock()
+		}
+	}
+}
+
+// sessionCapMiddleware caps the number of simultaneous sessions of the whole
+// server. When it is full, new sessions get a single static frame instead of
+// the animated banner. A max of 0 disables the cap.
+func sessionCapMiddleware(max int) wish.Middleware {
+	var sessions atomic.Int32
+	return func(next ssh.Handler) ssh.Handler {
+		return func(sess ssh.Session) {
+			if max <= 0 {
+				next(sess)
+				return
+			}
+			if sessions.Add(1) > int32(max) {
+				sessions.Add(-1)
+				getSessionStats(sess).setOutcome("server_full")
+				wish.Print(sess, serverFullFrame(sess))
+				return
+			}
+			next(sess)
+			sessions.Add(-1)
+		}
+	}
+}
+
+// serverFullFrame renders the banner once, without animation, and tells the
+// visitor to come back later.
+func serverFullFrame(sess ssh.Session) string {
+	return staticFrame(sess, "The server is full, come back later, bozo.")
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/charmbracelet/log"
+	"github.com/charmbracelet/ssh"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var connectionsAccepted = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "bozo_connections_total",
+	Help: "Connections accepted, by listener.",
+}, []string{"listener"})
+
+// listenerConfig is an address the server listens on. The label tells the
+// listeners apart in logs and metrics, e.g. to compare the scanners on port 22
+// with the ones on 2222.
+type listenerConfig struct {
+	Address string `json:"address"`
+	Label   string `json:"label"`
+	// Mode is "ssh" for plain SSH, the default, or "websocket" for SSH
+	// tunneled through WebSocket connections to Path.
+	Mode string `json:"mode"`
+	Path string `json:"path"`
+}
+
+// listen opens the listener described by cfg.
+func listen(cfg listenerConfig) (net.Listener, error) {
+	switch cfg.Mode {
+	case "", "ssh":
+		return net.Listen("tcp", cfg.Address)
+	case "websocket":
+		path := cfg.Path
+		if path == "" {
+			path = "/"
+		}
+		return listenWebSocket(cfg.Address, path)
+	default:
+		return nil, fmt.Errorf("unknown listener mode %q", cfg.Mode)
+	}
+}
+
+const listenerKey contextKey = "listener"
+
+// labeledListener remembers on every connection it accepts where it came in.
+type labeledListener struct {
+	net.Listener
+	label string
+}
+
+type labeledConn struct {
+	net.Conn
+	label string
+}
+
+func (l labeledListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &labeledConn{Conn: conn, label: l.label}, nil
+}
+
+// listenerCallback stores the label of the listener in the context. It has to
+// be the first connection callback, so it sees the accepted connection.
+func listenerCallback(ctx ssh.Context, conn net.Conn) net.Conn {
+	if c, ok := conn.(*labeledConn); ok {
+		ctx.SetValue(listenerKey, c.label)
+		connectionsAccepted.WithLabelValues(c.label).Inc()
+	}
+	return conn
+}
+
+// listenerLabel returns the label of the listener the connection of ctx came
+// in on.
+func listenerLabel(ctx ssh.Context) string {
+	label, _ := ctx.Value(listenerKey).(string)
+	return label
+}
+
+// openListeners opens all listeners, admitting connections with admission.
+func openListeners(cfgs []listenerConfig, admission *admission) ([]labeledListener, error) {
+	if len(cfgs) == 0 {
+		return nil, errors.New("no listeners configured")
+	}
+	var listeners []labeledListener
+	for _, cfg := range cfgs {
+		l, err := listen(cfg)
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			return nil, err
+		}
+		l = admissionListener{Listener: l, admission: admission}
+		listeners = append(listeners, labeledListener{Listener: l, label: cfg.Label})
+	}
+	return listeners, nil
+}
+
+// serveListeners serves s on all listeners. Errors of listeners that stop
+// serving, other than the server being shut down, are sent to errs.
+func serveListeners(s *ssh.Server, listeners []labeledListener, errs chan<- error) {
+	for _, l := range listeners {
+		log.Info("Starting SSH server", "address", l.Addr(), "listener", l.label)
+		go func() {
+			if err := s.Serve(l); err != nil && !errors.Is(err, ssh.ErrServerClosed) {
+				errs <- err
+			}
+		}()
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"runtime"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var tickIntervalSeconds = promauto.NewGauge(prometheus.GaugeOpts{
+	Name: "bozo_tick_interval_seconds",
+	Help: "Interval the sessions are ticked at, longer while the server is busy.",
+})
+
+// busyConfig slows the animations of all sessions down while the server is
+// busy, so it stays responsive during scan storms. Once the sessions or the
+// CPU usage reach their high watermark, the sessions are ticked every
+// Interval until both are below their low watermarks again.
+type busyConfig struct {
+	// HighSessions is the number of sessions at which the server counts as
+	// busy, 0 disables it. The sessions are capped by max_sessions first, so
+	// the default only matters once max_sessions is raised above it.
+	HighSessions int `json:"high_sessions"`
+	LowSessions  int `json:"low_sessions"`
+	// HighCPU is the share of all CPUs the process uses at which the server
+	// counts as busy, 0 disables it. It is only measured on unix.
+	HighCPU  float64  `json:"high_cpu"`
+	LowCPU   float64  `json:"low_cpu"`
+	Interval duration `json:"interval"`
+}
+
+// loadCheckInterval is how often the load is measured.
+const loadCheckInterval = time.Second
+
+// loadMonitor tells when the server gets busy and when it calms down.
+type loadMonitor struct {
+	cfg     busyConfig
+	busy    bool
+	checked time.Time
+	cpu     time.Duration
+}
+
+func newLoadMonitor(cfg busyConfig) *loadMonitor {
+	// Without sensible low watermarks the server would never calm down.
+	if cfg.LowSessions <= 0 || cfg.LowSessions > cfg.HighSessions {
+		cfg.LowSessions = cfg.HighSessions
+	}
+	if cfg.LowCPU <= 0 || cfg.LowCPU > cfg.HighCPU {
+		cfg.LowCPU = cfg.HighCPU
+	}
+	l := &loadMonitor{cfg: cfg, checked: time.Now()}
+	l.cpu, _ = processCPUTime()
+	return l
+}
+
+// interval measures the load with sessions open at most once every
+// loadCheckInterval and returns the interval to tick the sessions at.
+func (l *loadMonitor) interval(sessions int) time.Duration {
+	if now := time.Now(); now.Sub(l.checked) >= loadCheckInterval {
+		usage := l.cpuUsage(now)
+		switch {
+		case !l.busy && (l.cfg.HighSessions > 0 && sessions >= l.cfg.HighSessions ||
+			l.cfg.HighCPU > 0 && usage >= l.cfg.HighCPU):
+			l.busy = true
+			log.Warn("Server busy, slowing down animations", "sessions", sessions, "cpu", usage)
+		case l.busy && (l.cfg.HighSessions <= 0 || sessions < l.cfg.LowSessions) &&
+			(l.cfg.HighCPU <= 0 || usage < l.cfg.LowCPU):
+			l.busy = false
+			log.Info("Server no longer busy", "sessions", sessions, "cpu", usage)
+		}
+	}
+	if l.busy && l.cfg.Interval > 0 {
+		return time.Duration(l.cfg.Interval)
+	}
+	return tickInterval
+}
+
+// cpuUsage is the share of all CPUs the process used since the last check.
+func (l *loadMonitor) cpuUsage(now time.Time) float64 {
+	cpu, ok := processCPUTime()
+	wall := now.Sub(l.checked)
+	used := cpu - l.cpu
+	l.cpu, l.checked = cpu, now
+	if !ok {
+		return 0
+	}
+	return used.Seconds() / (wall.Seconds() * float64(runtime.NumCPU()))
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"io"
+	"os"
+
+	"github.com/charmbracelet/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// syslogQueue is how many records are buffered for syslog before new ones are
+// dropped.
+const syslogQueue = 1024
+
+var logRecordsDropped = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "bozo_log_records_dropped_total",
+	Help: "Log records an output failed to write or had no room for, by output.",
+}, []string{"output"})
+
+// logFileConfig configures logging to a file that is rotated once it grows too
+// big, so a busy server doesn't fill up the disk with connection logs.
+type logFileConfig struct {
+	// Path of the log file, file logging is disabled when empty.
+	Path string `json:"path"`
+	// MaxSizeMB is the size at which the file is rotated.
+	MaxSizeMB int `json:"max_size_mb"`
+	// MaxAgeDays is how long rotated files are kept, 0 keeps them forever.
+	MaxAgeDays int `json:"max_age_days"`
+	// MaxBackups is how many rotated files are kept, 0 keeps all of them.
+	MaxBackups int `json:"max_backups"`
+	// Compress gzips rotated files.
+	Compress bool `json:"compress"`
+}
+
+// setupLogOutputs makes the default logger write to all configured outputs.
+// Logs always go to stderr.
+func setupLogOutputs(cfg config) error {
+	outputs := []io.Writer{logOutput{"stderr", os.Stderr}}
+	if cfg.Syslog.Enabled {
+		w, err := newSyslogWriter(cfg.Syslog)
+		if err != nil {
+			return err
+		}
+		outputs = append(outputs, newAsyncLogOutput("syslog", w, syslogQueue))
+	}
+	if cfg.LogFile.Path != "" {
+		outputs = append(outputs, logOutput{"file", &lumberjack.Logger{
+			Filename:   cfg.LogFile.Path,
+			MaxSize:    cfg.LogFile.MaxSizeMB,
+			MaxAge:     cfg.LogFile.MaxAgeDays,
+			MaxBackups: cfg.LogFile.MaxBackups,
+			LocalTime:  true,
+			Compress:   cfg.LogFile.Compress,
+		}})
+	}
+	if len(outputs) > 1 {
+		log.SetOutput(io.MultiWriter(outputs...))
+	}
+	return nil
+}
+
+// logOutput is one of the outputs of the logger. A failing output must not
+// keep the records from the others, so its errors are counted and dropped
+// instead of being returned to io.MultiWriter.
+type logOutput struct {
+	name string
+	w    io.Writer
+}
+
+func (o logOutput) Write(p []byte) (int, error) {
+	if _, err := o.w.Write(p); err != nil {
+		logRecordsDropped.WithLabelValues(o.name).Inc()
+	}
+	return len(p), nil
+}
+
+// asyncLogOutput writes records to an output that can stall, like a remote
+// syslog server, in the background. Records are dropped while its queue is
+// full, so logging never blocks on it.
+type asyncLogOutput struct {
+	name    string
+	records chan []byte
+}
+
+func newAsyncLogOutput(name string, w io.Writer, queue int) *asyncLogOutput {
+	o := &asyncLogOutput{name: name, records: make(chan []byte, queue)}
+	go func() {
+		out := logOutput{name, w}
+		for p := range o.records {
+			out.Write(p)
+		}
+	}()
+	return o
+}
+
+func (o *asyncLogOutput) Write(p []byte) (int, error) {
+	// The logger reuses its buffer, the record has to be copied.
+	record := append([]byte(nil), p...)
+	select {
+	case o.records <- record:
+	default:
+		logRecordsDropped.WithLabelValues(o.name).Inc()
+	}
+	return len(p), nil
+}
@@ -0,0 +1,25 @@
+package main
+
+import "github.com/charmbracelet/log"
+
+// logLevels are the levels SIGUSR1 and SIGUSR2 step through, from the most to
+// the least verbose.
+var logLevels = []log.Level{log.DebugLevel, log.InfoLevel, log.WarnLevel, log.ErrorLevel}
+
+func stepLogLevel(level log.Level, step int) log.Level {
+	i := 0
+	for i < len(logLevels)-1 && logLevels[i] < level {
+		i++
+	}
+	i = min(max(i+step, 0), len(logLevels)-1)
+	return logLevels[i]
+}
+
+// setLogLevel changes the level of the default logger. The change is logged
+// regardless of the new level.
+func setLogLevel(level log.Level) {
+	old := log.GetLevel()
+	log.SetLevel(min(level, log.InfoLevel))
+	log.Info("Changed log level", "from", old, "to", level)
+	log.SetLevel(level)
+}
@@ -0,0 +1,7 @@
+//go:build !unix
+
+package main
+
+// handleLogLevelSignals does nothing, there are no SIGUSR1 and SIGUSR2 on this
+// platform.
+func handleLogLevelSignals(audit *auditLog) {}
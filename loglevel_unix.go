@@ -0,0 +1,31 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/charmbracelet/log"
+	"golang.org/x/sys/unix"
+)
+
+// handleLogLevelSignals changes the log level at runtime: SIGUSR1 makes the
+// logs more verbose, SIGUSR2 less. Changes are recorded in the audit log. It
+// never returns.
+func handleLogLevelSignals(audit *auditLog) {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGUSR1, syscall.SIGUSR2)
+	for s := range sig {
+		step := 1
+		if s == syscall.SIGUSR1 {
+			step = -1
+		}
+		level := stepLogLevel(log.GetLevel(), step)
+		setLogLevel(level)
+		if err := audit.record("signal:"+unix.SignalName(s.(syscall.Signal)), "set-log-level", level.String()); err != nil {
+			log.Error("Could not write audit log", "error", err)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/spinner"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/ssh"
+)
+
+// reverseDNSTimeout is how long looking up the hostname of a visitor may take.
+const reverseDNSTimeout = 3 * time.Second
+
+// lookupMsg carries what the lookups found out about the visitor.
+type lookupMsg struct {
+	hostname string
+	country  string
+	asn      uint
+	asnOrg   string
+	zones    []string
+}
+
+// pendingDNSBL returns the blocklist lookup of the session, if one was
+// started.
+func pendingDNSBL(ctx ssh.Context) *dnsblResult {
+	r, _ := ctx.Value(dnsblKey).(*dnsblResult)
+	return r
+}
+
+// lookups looks up the visitor in the background, it is nil if no lookups
+// are enabled.
+func lookups(geo *geoIP, dnsbl *dnsblResult, ip net.IP, reverseDNS bool) tea.Cmd {
+	if !geo.configured() && dnsbl == nil && !reverseDNS {
+		return nil
+	}
+	return func() tea.Msg {
+		var msg lookupMsg
+		if reverseDNS {
+			ctx, cancel := context.WithTimeout(context.Background(), reverseDNSTimeout)
+			if names, err := net.DefaultResolver.LookupAddr(ctx, ip.String()); err == nil && len(names) > 0 {
+				msg.hostname = strings.TrimSuffix(names[0], ".")
+			}
+			cancel()
+		}
+		// The visitor is waiting for these anyway.
+		geo.wait()
+		msg.country = geo.country(ip)
+		msg.asn, msg.asnOrg = geo.asn(ip)
+		if dnsbl != nil {
+			<-dnsbl.done
+			msg.zones = dnsbl.zones
+		}
+		return msg
+	}
+}
+
+func newLookupSpinner(m model) spinner.Model {
+	return spinner.New(spinner.WithSpinner(spinner.Dot), spinner.WithStyle(m.txtStyle))
+}
+
+// lookupView is what the lookups found out, or a spinner while they are
+// running.
+func (m model) lookupView() string {
+	if m.lookingUp {
+		if m.still() {
+			return " …"
+		}
+		return " " + m.spinner.View()
+	}
+	var details []string
+	if m.lookup.hostname != "" {
+		details = append(details, m.lookup.hostname)
+	}
+	if m.lookup.asn != 0 {
+		details = append(details, fmt.Sprintf("AS%d %s", m.lookup.asn, m.lookup.asnOrg))
+	}
+	if m.lookup.country != "" {
+		details = append(details, strings.TrimSpace(countryFlag(m.lookup.country)+" "+m.lookup.country))
+	}
+	var view string
+	if len(details) > 0 {
+		view = " (" + strings.Join(details, ", ") + ")"
+	}
+	if len(m.lookup.zones) > 0 {
+		view += "\nListed on " + strings.Join(m.lookup.zones, ", ") + ", tsk tsk."
+	}
+	return view
+}
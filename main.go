@@ -6,6 +6,7 @@ package main
 import (
 	"context"
 	"errors"
+	"flag"
 	"fmt"
 	"github.com/muesli/termenv"
 	"github.com/teacat/noire"
@@ -13,27 +14,21 @@ import (
 	"os"
 	"os/signal"
 	"strings"
-	"sync/atomic"
 	"syscall"
 	"time"
 
+	"github.com/charmbracelet/bubbles/spinner"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/charmbracelet/log"
 	"github.com/charmbracelet/ssh"
 	"github.com/charmbracelet/wish"
-	"github.com/charmbracelet/wish/activeterm"
 	"github.com/charmbracelet/wish/bubbletea"
-	"github.com/charmbracelet/wish/logging"
 )
 
 const (
-	host      = "0.0.0.0"
-	port      = "22"
-	step      = 15.0
-	gradient  = 6.0
-	angle     = 6.0
-	maxGuests = 3
+	host = "0.0.0.0"
+	port = "22"
 )
 
 const graphic = `⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⣀⠀⠀⠀⠀⠀⠀⠀⠀⠀
@@ -48,29 +43,206 @@ const graphic = `⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⣀⠀⠀⠀⠀⠀⠀
 ⠀⠀⠀⠀⠀⢻⣄⣠⣤⣴⠟⠛⠛⠛⢧⣤⣤⣀⡾⠁⠀⠀⠀⠀
 `
 
+var (
+	configPath   = flag.String("config", "config.json", "path to the JSON config file")
+	pprofAddress = flag.String("pprof", "", "serve pprof on this loopback address, e.g. 127.0.0.1:6060")
+)
+
 func main() {
-	var guestCount atomic.Int32
+	flag.Usage = usage
+	flag.Parse()
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		log.Fatal("Could not load config", "error", err)
+	}
+	if flag.NArg() > 0 {
+		os.Exit(runCommand(cfg, flag.Args()))
+	}
+	serve(cfg)
+}
+
+func serve(cfg config) {
+	if err := setupLogOutputs(cfg); err != nil {
+		log.Fatal("Could not set up logging", "error", err)
+	}
+	level, err := log.ParseLevel(cfg.LogLevel)
+	if err != nil {
+		log.Fatal("Could not set log level", "error", err)
+	}
+	log.SetLevel(level)
+	if cfg.Sandbox.Enabled {
+		if err := enterLandlock(cfg.Sandbox); err != nil {
+			log.Fatal("Could not enter sandbox", "error", err)
+		}
+	}
+	audit, err := openAuditLog(cfg.AuditLog)
+	if err != nil {
+		log.Fatal("Could not open audit log", "error", err)
+	}
+	go handleLogLevelSignals(audit)
+	keys, err := loadHostKeys(cfg.HostKeys)
+	if err != nil {
+		log.Fatal("Could not load host keys", "error", err)
+	}
+	if cfg.MetricsAddress != "" {
+		go serveMetrics(cfg.MetricsAddress)
+	}
+	if *pprofAddress != "" {
+		go servePprof(*pprofAddress)
+	}
+	visitors, err := loadVisitorCounter(cfg.VisitorsFile)
+	if err != nil {
+		log.Fatal("Could not load visitor counter", "error", err)
+	}
+	go visitors.run()
+	db, err := openStore(cfg.Database)
+	if err != nil {
+		log.Fatal("Could not open database", "error", err)
+	}
+	defer db.close()
+	geo, err := openGeoIP(cfg.GeoIP)
+	if err != nil {
+		log.Fatal("Could not open GeoIP database", "error", err)
+	}
+	stats := newServerStats(visitors)
+	bus := newEventBus()
+	sampler := newLogSampler(cfg.LogSampling)
+	go sampler.run()
+	subscribeLogSink(bus, stats, sampler)
+	subscribeVisitorSink(bus, stats)
+	subscribeStoreSink(bus, db)
+	if cfg.Fail2banLog != "" {
+		f, err := os.OpenFile(cfg.Fail2banLog, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o640)
+		if err != nil {
+			log.Fatal("Could not open fail2ban log", "error", err)
+		}
+		defer f.Close()
+		subscribeFail2banSink(bus, f)
+	}
+	if cfg.Statsd.Address != "" {
+		c, err := newStatsdClient(cfg.Statsd)
+		if err != nil {
+			log.Fatal("Could not set up statsd", "error", err)
+		}
+		subscribeStatsdSink(bus, c)
+	}
+	if cfg.Discord.WebhookURL != "" {
+		subscribeDiscordSink(bus, db, cfg.Discord)
+	}
+	if cfg.Slack.WebhookURL != "" {
+		if err := subscribeSlackSink(bus, db, cfg.Slack); err != nil {
+			log.Fatal("Could not parse the Slack templates", "error", err)
+		}
+	}
+	if cfg.Telegram.Token != "" {
+		subscribeTelegramSink(bus, db, cfg.Telegram)
+	}
+	if cfg.Webhook.URL != "" {
+		if err := subscribeWebhookSink(bus, db, cfg.Webhook); err != nil {
+			log.Fatal("Could not parse the webhook body", "error", err)
+		}
+	}
+	if cfg.Ntfy.TopicURL != "" {
+		if err := subscribeNtfySink(bus, db, cfg.Ntfy); err != nil {
+			log.Fatal("Could not parse the ntfy templates", "error", err)
+		}
+	}
+	if cfg.Digest.SMTP != "" {
+		go runDigest(db, cfg.Digest)
+	}
+
+	acl, err := newACL(cfg.ACL, bus)
+	if err != nil {
+		log.Fatal("Could not load allow and deny lists", "error", err)
+	}
+	go acl.watch()
+	bans, err := loadBanList(db, bus)
+	if err != nil {
+		log.Fatal("Could not load bans", "error", err)
+	}
+	go bans.sync()
+	if cfg.Firewall.Backend != "" {
+		fw, err := newFirewall(cfg.Firewall, bans, bus)
+		if err != nil {
+			log.Fatal("Could not set up firewall", "error", err)
+		}
+		go fw.run()
+	}
+	autoBan := newAutoBanner(cfg.AutoBan, bans)
+	go autoBan.cleanup()
+	countries, err := newCountryPolicy(cfg.CountryPolicy, geo)
+	if err != nil {
+		log.Fatal("Could not set up country policy", "error", err)
+	}
+	blocklists := newDNSBL(cfg.DNSBL)
+	go blocklists.cleanup()
+	scanners := newScannerDetector(cfg.Scanners, bus)
+	go scanners.cleanup()
+	backoff := newBackoff(cfg.Backoff)
+	backoff.subscribe(bus)
+	go backoff.cleanup()
+	auth, err := newAuth(cfg.Auth, bus, backoff)
+	if err != nil {
+		log.Fatal("Could not load admin keys", "error", err)
+	}
+	go handleReloadSignals(audit, keys, auth)
+	limiter := newConnLimiter(cfg.RateLimit, bus)
+	go limiter.cleanup()
+
+	keepalives := newKeepalive(cfg.Keepalive)
+
+	algos, err := algorithms(cfg.Algorithms)
+	if err != nil {
+		log.Fatal("Invalid algorithm policy", "error", err)
+	}
+
+	sessions := newSessionRegistry()
+	go sessions.runTicker(newLoadMonitor(cfg.Busy))
+	memory := newMemoryWatchdog(cfg.Memory)
+	go memory.run(sessions)
+
+	// The limits and the abuse detection apply to every type of request.
+	routes := newRouter(
+		sessionCapMiddleware(cfg.MaxSessions),
+		memory.middleware(),
+		perIPLimitMiddleware(bus, cfg.MaxSessionsPerIP),
+		throttleMiddleware(cfg.Throttle),
+		guardMiddleware(cfg.SessionGuard),
+		keepalives.middleware(),
+		bans.middleware(),
+		statsMiddleware(bus, stats, geo),
+		envMiddleware(cfg.Env),
+		blocklists.middleware(),
+		scanners.middleware(),
+		pprofLabelMiddleware(),
+	)
+	chat := newChatHub(cfg.Chat, sessions)
+	banner := myCustomBubbleteaMiddleware(cfg, bus, stats, db, sessions, chat, geo)(func(ssh.Session) {})
+	routes.shell(banner, noPTYMiddleware()) // Bubble Tea apps require a PTY.
+	// Admins run the ssh commands, everyone else gets to see the banner.
+	routes.exec(banner, noPTYMiddleware(), adminCommandMiddleware(cfg, keys))
+	routes.subsystem("stats", statsSubsystem(stats))
+	routes.subsystem("sftp", sftpSubsystem(newFakeFS(fakeFiles), bus))
+
 	s, err := wish.NewServer(
-		wish.WithAddress(net.JoinHostPort(host, port)),
-		wish.WithHostKeyPath(".ssh/id_ed25519"),
-		wish.WithMiddleware(
-			myCustomBubbleteaMiddleware(),
-			activeterm.Middleware(), // Bubble Tea apps usually require a PTY.
-			func(next ssh.Handler) ssh.Handler {
-				return func(sess ssh.Session) {
-					if guestCount.Add(1) > maxGuests {
-						guestCount.Add(-1)
-						wish.Errorln(sess, "Rate limited")
-						return
-					}
-					next(sess)
-					if guestCount.Add(-1) < 0 {
-						guestCount.Store(0)
-					}
-				}
-			},
-			logging.Middleware(),
+		keys.option(),
+		keepalives.option(),
+		withAuthBanner(cfg.AuthBanner),
+		withConnCallbacks(
+			listenerCallback,
+			acl.connCallback,
+			bans.connCallback,
+			scanners.connCallback,
+			countries.connCallback,
+			autoBan.connCallback,
+			backoff.connCallback,
+			limiter.connCallback,
+			blocklists.connCallback,
+			recordConnStart,
 		),
+		auth.option(),
+		withServerConfig(algos, auth.serverConfig),
+		routes.option(),
 	)
 	if err != nil {
 		log.Error("Could not start server", "error", err)
@@ -78,42 +250,57 @@ func main() {
 
 	done := make(chan os.Signal, 1)
 	signal.Notify(done, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
-	log.Info("Starting SSH server", "host", host, "port", port)
-	go func() {
-		if err = s.ListenAndServe(); err != nil && !errors.Is(err, ssh.ErrServerClosed) {
-			log.Error("Could not start server", "error", err)
-			done <- nil
-		}
-	}()
-
-	<-done
-	log.Info("Stopping SSH server")
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer func() { cancel() }()
-	if err := s.Shutdown(ctx); err != nil && !errors.Is(err, ssh.ErrServerClosed) {
+	errs := make(chan error, len(cfg.Listeners))
+	listeners, err := openListeners(cfg.Listeners, newAdmission(cfg.Admission))
+	if err != nil {
+		log.Fatal("Could not start server", "error", err)
+	}
+	if err := dropPrivileges(cfg.RunAs); err != nil {
+		log.Fatal("Could not drop privileges", "error", err)
+	}
+	if cfg.Sandbox.Enabled {
+		if err := enterSeccomp(); err != nil {
+			log.Fatal("Could not enter sandbox", "error", err)
+		}
+	}
+	serveListeners(s, listeners, errs)
+
+	select {
+	case <-done:
+	case err := <-errs:
+		log.Error("Could not serve", "error", err)
+	}
+	// Stop accepting new connections and give the active sessions time to
+	// wrap up before cutting them off.
+	log.Info("Draining SSH server", "timeout", time.Duration(cfg.DrainTimeout))
+	deadline := time.Now().Add(time.Duration(cfg.DrainTimeout))
+	sessions.broadcast(drainMsg(deadline))
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+	err = s.Shutdown(ctx)
+	if errors.Is(err, context.DeadlineExceeded) {
+		sessions.broadcast(tea.Quit())
+		// Give the programs a moment to restore the terminals.
+		time.Sleep(time.Second)
+		err = s.Close()
+	}
+	if err != nil && !errors.Is(err, ssh.ErrServerClosed) {
 		log.Error("Could not stop server", "error", err)
 	}
+	log.Info("Stopped SSH server")
+	if err := visitors.flush(); err != nil {
+		log.Error("Could not persist visitor counter", "error", err)
+	}
 }
 
-func myCustomBubbleteaMiddleware() wish.Middleware {
-	newProg := func(m tea.Model, opts ...tea.ProgramOption) *tea.Program {
-		p := tea.NewProgram(m, opts...)
-		go func() {
-			var tick uint = 0
-			for {
-				tick++
-				p.Send(tickMsg(tick))
-				<-time.After(1000 / 24 * time.Millisecond)
-			}
-		}()
-		return p
-	}
+func myCustomBubbleteaMiddleware(cfg config, bus *eventBus, server *serverStats, db *store, sessions *sessionRegistry, chat *chatHub, geo *geoIP) wish.Middleware {
+	keys := newKeyMap(cfg.Keys)
 
 	teaHandler := func(s ssh.Session) *tea.Program {
-		// This should never fail, as we are using the activeterm middleware.
+		// This should never fail, as we are using the noPTY middleware.
 		pty, _, _ := s.Pty()
 
-		color := noire.NewHSV(0, 66, 100)
+		color := themes[0].color(0)
 		// When running a Bubble Tea app over SSH, you shouldn't use the default
 		// lipgloss.NewStyle function.
 		// That function will use the color profile from the os.Stdin, which is the
@@ -128,18 +315,71 @@ func myCustomBubbleteaMiddleware() wish.Middleware {
 		txtStyle := renderer.NewStyle().Foreground(lipgloss.Color("10"))
 		quitStyle := renderer.NewStyle().Foreground(lipgloss.Color("8"))
 		address := s.RemoteAddr()
+		guard := getSessionGuard(s)
+		width, height := guard.clamp(pty.Window.Width, pty.Window.Height)
 
+		lookup := lookups(geo, pendingDNSBL(s.Context()), remoteIP(address), cfg.ReverseDNS)
+		// The key the client logged in with is theirs for sure, one it only
+		// offered is good for a greeting.
+		clientKey, keyVerified := offeredKey(s.Context()), false
+		if fp := verifiedKey(s.Context()); fp != "" {
+			clientKey, keyVerified = fp, true
+		}
 		m := model{
-			term:      pty.Term,
-			address:   address,
-			width:     pty.Window.Width,
-			height:    pty.Window.Height,
-			color:     color,
-			style:     style,
-			txtStyle:  txtStyle,
-			quitStyle: quitStyle,
+			term:             pty.Term,
+			address:          address,
+			width:            width,
+			height:           height,
+			color:            color,
+			style:            style,
+			txtStyle:         txtStyle,
+			quitStyle:        quitStyle,
+			stats:            getSessionStats(s),
+			server:           server,
+			bus:              bus,
+			event:            sessionEvent(s),
+			db:               db,
+			chat:             chat,
+			banner:           sessionBanner(s),
+			admin:            isAdmin(s.Context()),
+			guard:            guard,
+			profile:          renderer.ColorProfile(),
+			frames:           &frameTimer{},
+			labels:           sessionLabels(s),
+			sessionLog:       newSessionLog(),
+			regions:          make(regions),
+			out:              &lockedWriter{w: s},
+			clipboard:        cfg.Clipboard,
+			reducedMotion:    cfg.ReducedMotion,
+			plain:            cfg.Plain,
+			guestbook:        cfg.Guestbook,
+			screensaverAfter: time.Duration(cfg.Screensaver),
+			lastInput:        time.Now(),
+			maxDeadline:      maxDeadline(s.Context(), cfg.Keepalive.MaxTimeout),
+			inputTimeout:     time.Duration(cfg.Keepalive.InputTimeout),
+			infoURL:          cfg.InfoURL,
+			contact:          cfg.Contact,
+			hyperlinks:       supportsHyperlinks(cfg.Hyperlinks, pty.Term, capturedEnv(s.Context())),
+			lookups:          lookup,
+			lookingUp:        lookup != nil,
+			country:          sessionCountry(s.Context()),
+			lang:             sessionLanguage(language(exposedEnv(s.Context(), cfg.Env.Expose)), sessionCountry(s.Context())),
+			offeredKey:       clientKey,
+			keyVerified:      keyVerified,
+			speed:            defaultSpeed,
+			exitAnimation:    cfg.ExitAnimation,
+			pages:            newPages(isAdmin(s.Context())),
+			keys:             keys,
 		}
-		return newProg(m, append(bubbletea.MakeOptions(s), tea.WithAltScreen())...)
+		m.spinner = newLookupSpinner(m)
+		if cfg.Captcha.Enabled && !m.admin {
+			m.captcha = newCaptcha(cfg.Captcha)
+		}
+		// The signals are meant for the server, which drains the sessions
+		// instead of having every program quit right away.
+		p := tea.NewProgram(m, append(bubbletea.MakeOptions(s), tea.WithOutput(m.out), tea.WithAltScreen(), tea.WithMouseCellMotion(), tea.WithoutSignalHandler())...)
+		sessions.add(s.Context(), p, m.country)
+		return p
 	}
 	return bubbletea.MiddlewareWithProgramHandler(teaHandler, termenv.ANSI256)
 }
@@ -155,53 +395,383 @@ type model struct {
 	style     lipgloss.Style
 	txtStyle  lipgloss.Style
 	quitStyle lipgloss.Style
+	stats     *sessionStats
+	server    *serverStats
+	bus       *eventBus
+	event     event
+	db        *store
+	chat      *chatHub
+	banner    string
+	// admin sessions get to see the stats screen.
+	admin bool
+	guard *sessionGuard
+	// profile is the color profile of the client's terminal.
+	profile termenv.Profile
+	// out is the output of the program, escape sequences sent besides the
+	// frames go there too. clipboard is what the y key copies, copied when.
+	out       *lockedWriter
+	clipboard string
+	copied    time.Time
+	// The footer links to infoURL and contact, as hyperlinks if the
+	// terminal supports them.
+	infoURL    string
+	contact    string
+	hyperlinks bool
+	// pendingSize is the size a burst of resizes ended at, applied once
+	// resizeEvery passed since they were last applied at resizedAt.
+	pendingSize *tea.WindowSizeMsg
+	resizedAt   time.Time
+	// debug shows the debug overlay, frames measures what it shows.
+	debug  bool
+	frames *frameTimer
+	// labels are the profiler labels of the session, the stages add theirs.
+	labels context.Context
+	// sessionLog is what the events page tails.
+	sessionLog *sessionLog
+	// regions are the static parts of the banner screen as last rendered.
+	regions regions
+	// theme is the index of the color theme in themes, speed the one of the
+	// animation speed in animationSpeeds. With reducedMotion the colors stand
+	// still and nothing else moves on its own either.
+	theme         int
+	speed         int
+	reducedMotion bool
+	// plain describes the banner instead of drawing it and keeps everything
+	// still, for screen readers.
+	plain bool
+	// country is where the visitor is from, online who else is here.
+	country string
+	online  onlineMsg
+	// lookups looks up the visitor, lookingUp tells whether it is still
+	// running and lookup is what it found out.
+	lookups   tea.Cmd
+	lookingUp bool
+	lookup    lookupMsg
+	spinner   spinner.Model
+	// lang is the language of the client's locale, if it sent one.
+	lang string
+	// offeredKey is the fingerprint of the key the client offered, regular
+	// is who that key belongs to once it is loaded. Anyone can offer any
+	// public key, keyVerified tells whether the client logged in with it.
+	offeredKey  string
+	keyVerified bool
+	regular     regular
+	// keys binds the keys to what they do.
+	keys keyMap
+	// pages are the child screens, page is the index of the one shown.
+	pages []page
+	page  int
+	// captcha is the challenge shown before the banner until it is passed.
+	captcha *captcha
+	// snake is the mini-game while it is played.
+	snake *snake
+	// hack is the hacking back sequence while it is shown, hackedBack tells
+	// whether it was shown at all.
+	hack       *hack
+	hackedBack bool
+	// With exitAnimation the banner dissolves for a moment when the visitor
+	// quits, leaving tells that it is dissolving since tick leftAt.
+	exitAnimation bool
+	leaving       bool
+	leftAt        uint
+	// konami are the last keys pressed, the Konami banner is shown until
+	// konamiUntil.
+	konami      [len(konamiCode)]string
+	konamiUntil time.Time
+	// screensaver bounces the banner around once there was no input for
+	// screensaverAfter since lastInput.
+	screensaver      *screensaver
+	screensaverAfter time.Duration
+	lastInput        time.Time
+	// The session is dropped at maxDeadline, or once there was no input for
+	// inputTimeout. The countdown warns about it.
+	maxDeadline  time.Time
+	inputTimeout time.Duration
+	// toast is shown below the page until toastUntil, muted mutes the ones
+	// about new visitors.
+	toast      string
+	toastUntil time.Time
+	muted      bool
+	// name is what the visitor told us their name is.
+	name      string
+	guestbook guestbookConfig
+	// shutdown is when the server goes down, once it is draining.
+	shutdown time.Time
 }
 
-type tickMsg uint
+// drainMsg tells the session when the server goes down.
+type drainMsg time.Time
+
+type tickMsg struct {
+	tick uint
+	sent time.Time
+}
 
 func (m model) Init() tea.Cmd {
-	return nil
+	cmds := []tea.Cmd{
+		m.guard.cmd(loadRegular(m.db, m.offeredKey), nil),
+		m.guard.cmd(loadPreferences(m.db, m.offeredKey), nil),
+	}
+	if m.lookingUp {
+		// Without the lookup the visitor is shown as unknown.
+		cmds = append(cmds, m.guard.cmd(m.lookups, lookupMsg{}), m.spinner.Tick)
+	}
+	return tea.Batch(cmds...)
+}
+
+func (m model) Update(msg tea.Msg) (next tea.Model, cmd tea.Cmd) {
+	m.stage("update", func() { next, cmd = m.update(msg) })
+	return next, cmd
 }
 
-func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+func (m model) update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	m.sessionLog.record(msg)
 	switch msg := msg.(type) {
 	case tea.WindowSizeMsg:
-		m.height = msg.Height
-		m.width = msg.Width
+		return m.resize(msg)
+	case resizedMsg:
+		if m.pendingSize != nil {
+			return m.applySize(*m.pendingSize)
+		}
 	case tea.KeyMsg:
-		switch msg.String() {
-		case "q", "ctrl+c":
-			return m, tea.Quit
+		m.stats.keys.Add(1)
+		m.lastInput = time.Now()
+		e := m.event
+		e.Type = eventKeypress
+		e.Time = time.Now()
+		e.Key = msg.String()
+		m.bus.publish(e)
+		if m.screensaver != nil {
+			m.screensaver = nil
+			return m, nil
+		}
+		if m.captcha != nil {
+			return m.updateCaptcha(msg)
+		}
+		var konami bool
+		if m, konami = m.trackKonami(msg.String()); konami {
+			return m.show(0)
 		}
+		return m.handleKey(msg)
+	case tea.MouseMsg:
+		m.lastInput = time.Now()
+		if m.screensaver != nil {
+			m.screensaver = nil
+			return m, nil
+		}
+		if m.captcha != nil || m.snake != nil || m.hack != nil {
+			return m, nil
+		}
+		if m.page == 0 && msg.Action == tea.MouseActionPress && msg.Button == tea.MouseButtonLeft {
+			return m.click(msg.X, msg.Y)
+		}
+		return m.updatePage(msg)
+	case regularMsg:
+		m.regular = regular(msg)
+	case copiedMsg:
+		m.copied = time.Time(msg)
+	case lookupMsg:
+		m.lookup = msg
+		m.lookingUp = false
+	case spinner.TickMsg:
+		if m.lookingUp {
+			var cmd tea.Cmd
+			m.spinner, cmd = m.spinner.Update(msg)
+			return m, cmd
+		}
+	case onlineMsg:
+		m.online = msg
+	case connectedMsg:
+		m = m.connectedToast(msg)
+	case snakeScoresMsg:
+		if m.snake != nil {
+			m.snake.scores, m.snake.err = msg.scores, msg.err
+		}
+	case preferencesMsg:
+		m = m.applyPreferences(preferences(msg))
+	case setPreferencesMsg:
+		m = m.applyPreferences(preferences(msg))
+		return m, m.savePreferences()
+	case nameMsg:
+		m.name = string(msg)
+		e := m.event
+		e.Type = eventName
+		e.Time = time.Now()
+		e.Name = m.name
+		m.bus.publish(e)
+	case shedMsg:
+		return m.shed(msg)
+	case drainMsg:
+		m.shutdown = time.Time(msg)
 	case tickMsg:
-		m.tick = uint(msg)
-		m.color = m.color.AdjustHue(step)
+		tickDelaySeconds.Observe(time.Since(msg.sent).Seconds())
+		m.tick = msg.tick
+		step := themes[m.theme].step
+		if m.konamiActive() {
+			step = konamiTheme.step
+		}
+		if !m.still() {
+			m.color = m.color.AdjustHue(step * animationSpeeds[m.speed].factor)
+		}
+		if !m.hackedBack && !m.still() && m.page == 0 && m.captcha == nil && m.snake == nil && !m.typing() && time.Since(m.stats.start) >= hackAfter {
+			m = m.startHack()
+		}
+		if m.screensaver == nil && m.screensaverAfter > 0 && !m.still() && !m.leaving && m.captcha == nil && m.snake == nil && m.hack == nil && time.Since(m.lastInput) >= m.screensaverAfter {
+			m = m.startScreensaver()
+		}
+		m = m.stepScreensaver()
+		var cmd tea.Cmd
+		if m, cmd = m.stepCaptcha(); cmd != nil {
+			return m, cmd
+		}
+		return m, tea.Batch(m.stepSnake(), m.stepFarewell(), m.stepInputTimeout())
+	default:
+		return m.updatePages(msg)
 	}
 	return m, nil
 }
 
+// handleKey handles pressing a key, or clicking the button of the key.
+func (m model) handleKey(msg tea.KeyMsg) (model, tea.Cmd) {
+	if m.leaving {
+		return m.quit(msg)
+	}
+	if m.snake != nil {
+		return m.updateSnake(msg)
+	}
+	if m.hack != nil {
+		return m.updateHack(msg)
+	}
+	if m.typing() {
+		switch {
+		case msg.String() == "ctrl+c":
+			return m, tea.Quit
+		case m.keys.matches(msg, "next_page"), m.keys.matches(msg, "prev_page"):
+			m, cmd, _ := m.navigate(msg)
+			return m, cmd
+		}
+		return m.updatePage(msg)
+	}
+	switch {
+	case msg.String() == "ctrl+c", m.keys.matches(msg, "quit"):
+		return m.quit(msg)
+	case m.keys.matches(msg, "snake"):
+		m.snake = newSnake(m.width, m.height)
+		return m, nil
+	case m.keys.matches(msg, "hack"):
+		return m.startHack(), nil
+	case m.keys.matches(msg, "debug"):
+		m.debug = !m.debug
+		return m, nil
+	case m.keys.matches(msg, "copy"):
+		return m, m.copyToClipboard(m.clipboardText())
+	case m.keys.matches(msg, "theme"):
+		m = m.cycleTheme()
+		return m, m.savePreferences()
+	case m.keys.matches(msg, "motion"):
+		m.reducedMotion = !m.reducedMotion
+		return m, m.savePreferences()
+	case m.keys.matches(msg, "plain"):
+		m.plain = !m.plain
+		return m, nil
+	case m.keys.matches(msg, "mute"):
+		return m.toggleMuted(), nil
+	}
+	if m, cmd, ok := m.navigate(msg); ok {
+		return m, cmd
+	}
+	return m.updatePage(msg)
+}
+
 func (m model) View() string {
-	msg := fmt.Sprintf("Your IP is %v", m.address.(*net.TCPAddr).IP)
-	return lolcat(graphic, &m.color, m.style) + "\n" + m.txtStyle.Render(msg) + "\n" + m.quitStyle.Render("Press 'q' to quit\n")
+	// Bubble Tea doesn't write a frame that didn't change, so a client that
+	// can't keep up gets the last one again instead of a backlog.
+	if m.frames.last != "" && m.out.backedUp() {
+		framesDroppedTotal.Inc()
+		return m.frames.last
+	}
+	frames := m.stats.frames.Add(1)
+	start := time.Now()
+	var view string
+	m.stage("render", func() { view = m.render() })
+	d := time.Since(start)
+	frameRenderSeconds.Observe(d.Seconds())
+	m.frames.frame(d, frames)
+	m.frames.last = m.place(view)
+	return m.frames.last
+}
+
+// render renders the page that is shown.
+func (m model) render() string {
+	var view string
+	switch {
+	case m.screensaver != nil:
+		// The screensaver takes up the whole window, there is no room for
+		// anything else.
+		return m.screensaverView()
+	case m.captcha != nil:
+		view = m.captchaView()
+	case m.leaving:
+		view = m.farewellView()
+	case m.snake != nil:
+		view = m.snakeView()
+	case m.hack != nil:
+		view = m.hackView()
+	case m.compact() && m.page == 0:
+		view = m.compactView()
+	case m.compact():
+		view = m.pages[m.page].view(m) + "\n" + m.compactTabBar()
+	default:
+		view = m.pages[m.page].view(m) + "\n" + m.tabBar()
+	}
+	if !m.shutdown.IsZero() {
+		left := max(time.Until(m.shutdown).Round(time.Second), 0)
+		view += "\n" + m.txtStyle.Render(fmt.Sprintf(translate(m.lang, "Server going down in %v, bozo."), left))
+	}
+	if toast := m.toastView(); toast != "" {
+		view += "\n" + toast
+	}
+	if countdown := m.countdownView(); countdown != "" {
+		view += "\n" + m.quitStyle.Render(countdown)
+	}
+	if m.debug {
+		view += "\n" + m.debugView()
+	}
+	return view
+}
+
+func (m model) bannerView() string {
+	help := fmt.Sprintf(translate(m.lang, "Press '%s' to quit\n"), m.keys.hint("quit", "ctrl+c"))
+	if key := m.keys.first("help"); key != "" {
+		help = fmt.Sprintf(translate(m.lang, "Press '%s' for help, '%s' to quit\n"), key, m.keys.hint("quit", "ctrl+c"))
+	}
+	if time.Since(m.copied) < copiedFor {
+		help = translate(m.lang, "Copied to your clipboard, if your terminal lets us.\n")
+	}
+	// Rendered without the newline, lipgloss would pad the empty line after
+	// it in front of the footer.
+	view := m.bannerTop() + m.buttonBar() + "\n" + m.regions.render("help", m.quitStyle, strings.TrimSuffix(help, "\n")) + "\n"
+	if footer := m.footerView(); footer != "" {
+		view += m.regions.render("footer", m.quitStyle, footer) + "\n"
+	}
+	return view
+}
+
+// bannerTop is the part of the banner screen above the buttons.
+func (m model) bannerTop() string {
+	msg := fmt.Sprintf(translate(m.lang, "Your IP is %v"), m.address.(*net.TCPAddr).IP) + m.lookupView()
+	if greeting := m.greeting(); greeting != "" {
+		msg += "\n" + greeting
+	}
+	msg += "\n" + m.onlineView()
+	return m.renderBanner() + "\n" + m.regions.render("info", m.txtStyle, msg) + "\n"
 }
 
-func lolcat(msg string, initialColor *noire.Color, style lipgloss.Style) string {
-	builder := strings.Builder{}
-	rowColor := *initialColor
-	charColor := rowColor
-	for _, c := range []rune(msg) {
-		if c == '\n' {
-			builder.WriteRune(c)
-			rowColor = rowColor.AdjustHue(angle)
-			charColor = rowColor
-			continue
-		}
-		builder.WriteString(style.Foreground(noireColorToLipglossColor(charColor)).Render(string(c)))
-		charColor = charColor.AdjustHue(gradient)
-	}
-	return builder.String()
+// lolcat renders msg in the default theme.
+func lolcat(msg string, initialColor *noire.Color, profile termenv.Profile) string {
+	return themes[0].lolcat(msg, *initialColor, profile)
 }
 
 func noireColorToLipglossColor(color noire.Color) lipgloss.Color {
-	return lipgloss.Color(fmt.Sprintf("#%s", color.Hex()))
+	return lipgloss.Color(hexColor(color))
 }
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"runtime/metrics"
+	"sync/atomic"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/log"
+	"github.com/charmbracelet/ssh"
+	"github.com/charmbracelet/wish"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var memoryBytes = promauto.NewGauge(prometheus.GaugeOpts{
+	Name: "bozo_memory_bytes",
+	Help: "Memory the Go runtime holds from the system, as the memory watchdog sees it.",
+})
+
+// memoryConfig sheds load before the process runs out of memory, so the OOM
+// killer doesn't take the whole server down.
+type memoryConfig struct {
+	// LimitMB is the memory use in megabytes beyond which new sessions get
+	// the full frame instead of the banner, 0 disables the watchdog. New
+	// sessions are served again below 90% of it.
+	LimitMB int `json:"limit_mb"`
+	// ShedIdle closes the sessions without input for longer than it while
+	// the limit is reached, 0 leaves them open.
+	ShedIdle duration `json:"shed_idle"`
+}
+
+// memoryCheckInterval is how often the watchdog looks at the memory use.
+const memoryCheckInterval = 5 * time.Second
+
+// memoryWatchdog keeps track of whether the process is out of memory.
+type memoryWatchdog struct {
+	cfg  memoryConfig
+	full atomic.Bool
+	// used reads the memory use, memoryUsed outside of the tests.
+	used func() uint64
+}
+
+func newMemoryWatchdog(cfg memoryConfig) *memoryWatchdog {
+	return &memoryWatchdog{cfg: cfg, used: memoryUsed}
+}
+
+// memoryUsed is the memory the Go runtime holds from the system, close to
+// the resident size of the process.
+func memoryUsed() uint64 {
+	samples := []metrics.Sample{
+		{Name: "/memory/classes/total:bytes"},
+		{Name: "/memory/classes/heap/released:bytes"},
+	}
+	metrics.Read(samples)
+	return samples[0].Value.Uint64() - samples[1].Value.Uint64()
+}
+
+// run checks the memory use until the process exits. While it is beyond the
+// limit the idle sessions are asked to leave.
+func (w *memoryWatchdog) run(sessions *sessionRegistry) {
+	if w.cfg.LimitMB <= 0 {
+		return
+	}
+	for range time.Tick(memoryCheckInterval) {
+		w.check(sessions)
+	}
+}
+
+// check looks at the memory use once. The process is out of memory from the
+// limit on until the use drops below 90% of it.
+func (w *memoryWatchdog) check(sessions *sessionRegistry) {
+	limit := uint64(w.cfg.LimitMB) << 20
+	used := w.used()
+	memoryBytes.Set(float64(used))
+	switch {
+	case !w.full.Load() && used >= limit:
+		w.full.Store(true)
+		log.Warn("Out of memory, turning away new sessions", "used_mb", used>>20, "limit_mb", w.cfg.LimitMB)
+	case w.full.Load() && used < limit/10*9:
+		w.full.Store(false)
+		log.Info("Memory available again", "used_mb", used>>20)
+	}
+	if w.full.Load() && w.cfg.ShedIdle > 0 {
+		sessions.broadcast(shedMsg(w.cfg.ShedIdle))
+	}
+}
+
+// middleware serves the full frame instead of the banner while the process
+// is out of memory.
+func (w *memoryWatchdog) middleware() wish.Middleware {
+	return func(next ssh.Handler) ssh.Handler {
+		return func(sess ssh.Session) {
+			if w.full.Load() {
+				getSessionStats(sess).setOutcome("memory_full")
+				wish.Print(sess, serverFullFrame(sess))
+				return
+			}
+			next(sess)
+		}
+	}
+}
+
+// shedMsg asks the sessions idle for longer than it to leave.
+type shedMsg duration
+
+// shed quits the session if it has been idle for long enough.
+func (m model) shed(msg shedMsg) (model, tea.Cmd) {
+	if time.Since(m.lastInput) < time.Duration(msg) {
+		return m, nil
+	}
+	m.stats.setOutcome("shed")
+	return m, tea.Quit
+}
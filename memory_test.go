@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// shedRecorder is a program that passes on the shed messages it gets.
+type shedRecorder struct{ shed chan<- shedMsg }
+
+func (r shedRecorder) Init() tea.Cmd { return nil }
+func (r shedRecorder) View() string  { return "" }
+
+func (r shedRecorder) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if msg, ok := msg.(shedMsg); ok {
+		r.shed <- msg
+	}
+	return r, nil
+}
+
+// registerShedRecorder adds a program to sessions that records the shed
+// messages broadcast to it.
+func registerShedRecorder(t *testing.T, sessions *sessionRegistry) <-chan shedMsg {
+	t.Helper()
+	shed := make(chan shedMsg, 16)
+	p := tea.NewProgram(shedRecorder{shed}, tea.WithInput(nil), tea.WithOutput(io.Discard), tea.WithoutRenderer())
+	go p.Run()
+	t.Cleanup(p.Quit)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	sessions.add(ctx, p, "")
+	return shed
+}
+
+func TestMemoryWatchdogHysteresis(t *testing.T) {
+	w := newMemoryWatchdog(memoryConfig{LimitMB: 100})
+	sessions := newSessionRegistry()
+	for i, step := range []struct {
+		usedMB uint64
+		full   bool
+	}{
+		{50, false},
+		{99, false},
+		{100, true},
+		{95, true},
+		{90, true},
+		{89, false},
+		{95, false},
+		{120, true},
+	} {
+		w.used = func() uint64 { return step.usedMB << 20 }
+		w.check(sessions)
+		if got := w.full.Load(); got != step.full {
+			t.Errorf("step %d, %d MB used: full = %v, want %v", i, step.usedMB, got, step.full)
+		}
+	}
+}
+
+func TestMemoryWatchdogShedsIdleSessions(t *testing.T) {
+	w := newMemoryWatchdog(memoryConfig{LimitMB: 100, ShedIdle: duration(time.Minute)})
+	sessions := newSessionRegistry()
+	shed := registerShedRecorder(t, sessions)
+
+	w.used = func() uint64 { return 50 << 20 }
+	w.check(sessions)
+	select {
+	case <-shed:
+		t.Fatal("sessions shed below the limit")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	w.used = func() uint64 { return 100 << 20 }
+	w.check(sessions)
+	select {
+	case msg := <-shed:
+		if time.Duration(msg) != time.Minute {
+			t.Errorf("shed sessions idle for %v, want %v", time.Duration(msg), time.Minute)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("sessions not shed at the limit")
+	}
+}
+
+func TestMemoryWatchdogKeepsIdleSessionsWithoutShedIdle(t *testing.T) {
+	w := newMemoryWatchdog(memoryConfig{LimitMB: 100})
+	sessions := newSessionRegistry()
+	shed := registerShedRecorder(t, sessions)
+
+	w.used = func() uint64 { return 200 << 20 }
+	w.check(sessions)
+	if !w.full.Load() {
+		t.Fatal("not full beyond the limit")
+	}
+	select {
+	case <-shed:
+		t.Error("sessions shed without shed_idle")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/charmbracelet/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	frameRenderSeconds = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "bozo_frame_render_seconds",
+		Help:    "Time spent rendering a single frame in View.",
+		Buckets: prometheus.ExponentialBuckets(0.0001, 2, 14),
+	})
+	tickDelaySeconds = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "bozo_tick_delay_seconds",
+		Help:    "Delay between sending a tick and it being handled in Update.",
+		Buckets: prometheus.ExponentialBuckets(0.0001, 2, 14),
+	})
+	sessionWriteSeconds = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "bozo_session_write_seconds",
+		Help:    "Time spent writing output to a client, high values mean slow clients.",
+		Buckets: prometheus.ExponentialBuckets(0.0001, 2, 16),
+	})
+	framesDroppedTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "bozo_frames_dropped_total",
+		Help: "Frames skipped because the client was still busy with the previous ones.",
+	})
+)
+
+// serveMetrics serves the Prometheus metrics on addr until the process exits.
+func serveMetrics(addr string) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	log.Info("Serving metrics", "address", addr)
+	if err := http.ListenAndServe(addr, mux); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Error("Could not serve metrics", "error", err)
+	}
+}
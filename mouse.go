@@ -0,0 +1,77 @@
+package main
+
+import (
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// button is a clickable label on the banner screen, clicking it does the same
+// as pressing the key of action.
+type button struct {
+	label  string
+	action string
+}
+
+// buttons are the buttons of the banner screen, the ones of disabled actions
+// are left out.
+func (m model) buttons() []button {
+	all := []button{{"Help", "help"}, {"Leaderboard", "leaderboard"}, {"About", "about"}}
+	if m.admin {
+		all = append(all, button{"Stats", "stats"})
+	}
+	all = append(all, button{"Quit", "quit"})
+	buttons := all[:0]
+	for _, b := range all {
+		if m.keys.first(b.action) != "" {
+			buttons = append(buttons, b)
+		}
+	}
+	return buttons
+}
+
+const buttonGap = "  "
+
+func (m model) buttonBar() string {
+	labels := make([]string, 0, len(m.buttons()))
+	for _, b := range m.buttons() {
+		labels = append(labels, "[ "+b.label+" ]")
+	}
+	// The gaps are styled along with the labels, which doesn't show.
+	return m.regions.render("buttons", m.txtStyle, strings.Join(labels, buttonGap))
+}
+
+// keyMsg is the message of pressing key.
+func keyMsg(key string) tea.KeyMsg {
+	return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key)}
+}
+
+// click handles a left click at x, y on the banner screen: the banner cycles
+// the theme, the buttons do what their key does.
+func (m model) click(x, y int) (model, tea.Cmd) {
+	// The view is centered in the window, and Bubble Tea cuts off the top of
+	// views that are taller than the window.
+	view := m.render()
+	offsetX, offsetY := m.offset(view)
+	x -= offsetX
+	y += max(lipgloss.Height(view)-m.height, 0) - offsetY
+	if y < strings.Count(m.currentBanner(), "\n") {
+		m = m.cycleTheme()
+		return m, m.savePreferences()
+	}
+	if m.compact() {
+		return m, nil
+	}
+	if y != strings.Count(m.bannerTop(), "\n") {
+		return m, nil
+	}
+	for _, b := range m.buttons() {
+		w := lipgloss.Width("[ " + b.label + " ]")
+		if x >= 0 && x < w {
+			return m.handleKey(keyMsg(m.keys.first(b.action)))
+		}
+		x -= w + len(buttonGap)
+	}
+	return m, nil
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/ssh"
+	"github.com/charmbracelet/wish"
+	"github.com/charmbracelet/wish/bubbletea"
+	"github.com/muesli/termenv"
+)
+
+// noPTYMiddleware shows sessions without a PTY, e.g. "ssh host | cat", a
+// single static frame instead of rejecting them.
+func noPTYMiddleware() wish.Middleware {
+	return func(next ssh.Handler) ssh.Handler {
+		return func(sess ssh.Session) {
+			if _, _, ok := sess.Pty(); ok {
+				next(sess)
+				return
+			}
+			getSessionStats(sess).setOutcome("no_pty")
+			wish.Print(sess, staticFrame(sess, fmt.Sprintf("Your IP is %v", remoteIP(sess.RemoteAddr()))))
+		}
+	}
+}
+
+// staticFrame renders the banner once, without animation, followed by msg.
+func staticFrame(sess ssh.Session, msg string) string {
+	renderer := bubbletea.MakeRenderer(sess)
+	// Same minimum color profile as the Bubble Tea middleware enforces.
+	if renderer.ColorProfile() > termenv.ANSI256 {
+		renderer.SetColorProfile(termenv.ANSI256)
+	}
+	color := themes[0].color(0)
+	msg = renderer.NewStyle().Foreground(lipgloss.Color("10")).Render(msg)
+	frame := lolcat(sessionBanner(sess), &color, renderer.ColorProfile()) + "\n" + msg + "\n"
+	// There is no Bubble Tea program translating newlines for the PTY.
+	if _, _, ok := sess.Pty(); ok {
+		frame = strings.ReplaceAll(frame, "\n", "\r\n")
+	}
+	return frame
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"golang.org/x/time/rate"
+)
+
+var notificationsSent = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "bozo_notifications_total",
+	Help: "Notifications sent to the operator, by sink and result.",
+}, []string{"sink", "result"})
+
+// notifyConfig batches and rate limits the messages of a notification sink,
+// so scan storms don't spam the operator.
+type notifyConfig struct {
+	// Interval is how long notifications are collected into one message.
+	Interval duration `json:"interval"`
+	// PerMinute caps the messages sent a minute, 0 disables the cap. The
+	// notifications that don't make it wait for the next message.
+	PerMinute int `json:"per_minute"`
+	// MaxBatch caps the notifications in a message, the ones beyond it are
+	// only counted.
+	MaxBatch int `json:"max_batch"`
+}
+
+var defaultNotifyConfig = notifyConfig{
+	Interval:  duration(10 * time.Second),
+	PerMinute: 6,
+	MaxBatch:  10,
+}
+
+// notification is an event a sink tells the operator about. Visits counts
+// the earlier visits of the IP of connects, Score is the sessionScore of
+// disconnects.
+type notification struct {
+	event
+	Visits int
+	Score  int
+}
+
+// Visit is the number of the visit of a connect, counting this one.
+func (n notification) Visit() int {
+	return n.Visits + 1
+}
+
+// Stayed is how long the session of a disconnect lasted, to the second.
+func (n notification) Stayed() time.Duration {
+	return n.Duration.Round(time.Second)
+}
+
+// notifyEvents are the event types sinks can be told about. Of the auths, only
+// the ones that captured an answer to the keyboard-interactive challenge are.
+var notifyEvents = []eventType{eventConnect, eventDisconnect, eventAuth, eventUpload, eventBan, eventKonami, eventName, eventGuestbook}
+
+// eventFilter accepts the events of types.
+func eventFilter(sink string, types []string) func(event) bool {
+	for _, t := range types {
+		if !slices.Contains(notifyEvents, eventType(t)) {
+			log.Warn("Unknown notification event in config", "sink", sink, "event", t)
+		}
+	}
+	return func(e event) bool {
+		if e.Type == eventAuth && e.Answer == "" {
+			return false
+		}
+		return slices.Contains(types, string(e.Type))
+	}
+}
+
+// defaultTemplates are the messages for the event types a sink has no
+// template of its own for.
+var defaultTemplates = map[string]string{
+	"connect":    `New bozo {{.IP}} ({{or .Country "??"}}) {{.ClientVersion}}, {{if .Visits}}visit #{{.Visit}}{{else}}first visit{{end}}`,
+	"disconnect": `{{.IP}} ({{or .Country "??"}}) left after {{.Stayed}}, score {{.Score}}`,
+	"auth":       `{{.User}}@{{.IP}} answered {{printf "%q" .Answer}}`,
+	"upload":     `{{.IP}} tried to upload {{.Path}}`,
+	"ban":        `Banned {{.IP}}: {{.Reason}}`,
+	"konami":     `{{.IP}} entered the Konami code`,
+	"name":       `{{.IP}} says they are {{.Name}}`,
+	"guestbook":  `{{.Name}} signed the guestbook{{if .Flagged}} (needs approval){{end}}: {{.Message}}`,
+}
+
+// notifyTemplates are the message templates of a sink by event type, they
+// are executed with the notification.
+type notifyTemplates map[eventType]*template.Template
+
+// parseTemplates parses the templates of a sink over the default ones.
+func parseTemplates(sink string, texts map[string]string) (notifyTemplates, error) {
+	t := make(notifyTemplates)
+	for _, texts := range []map[string]string{defaultTemplates, texts} {
+		for typ, text := range texts {
+			tmpl, err := template.New(sink + "/" + typ).Parse(text)
+			if err != nil {
+				return nil, err
+			}
+			t[eventType(typ)] = tmpl
+		}
+	}
+	return t, nil
+}
+
+// render writes the line of every notification of batch, and how many were
+// dropped.
+func (t notifyTemplates) render(batch []notification, dropped int) (string, error) {
+	var b strings.Builder
+	for _, n := range batch {
+		tmpl, ok := t[n.Type]
+		if !ok {
+			fmt.Fprintf(&b, "%s from %s\n", n.Type, n.IP)
+			continue
+		}
+		if err := tmpl.Execute(&b, n); err != nil {
+			return "", err
+		}
+		b.WriteByte('\n')
+	}
+	if dropped > 0 {
+		fmt.Fprintf(&b, "…and %d more\n", dropped)
+	}
+	return b.String(), nil
+}
+
+// notifier collects the notifications of a sink and sends them in batches.
+type notifier struct {
+	name    string
+	cfg     notifyConfig
+	send    func(batch []notification, dropped int) error
+	limiter *rate.Limiter
+
+	mu      sync.Mutex
+	batch   []notification
+	dropped int
+}
+
+// subscribeNotifier has send called with the notifications of the events want
+// accepts, at most every interval of cfg. dropped counts the notifications
+// left out of the batch.
+func subscribeNotifier(bus *eventBus, db *store, name string, cfg notifyConfig, want func(event) bool, send func(batch []notification, dropped int) error) {
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultNotifyConfig.Interval
+	}
+	limit := rate.Inf
+	if cfg.PerMinute > 0 {
+		limit = rate.Limit(float64(cfg.PerMinute) / 60)
+	}
+	n := &notifier{name: name, cfg: cfg, send: send, limiter: rate.NewLimiter(limit, 1)}
+	bus.subscribe(name, 256, func(e event) {
+		if !want(e) {
+			return
+		}
+		no := notification{event: e}
+		if e.Type == eventConnect {
+			visits, err := db.visitsOf(e.IP.String())
+			if err != nil {
+				log.Error("Could not count visits", "error", err)
+			}
+			no.Visits = visits
+		}
+		if e.Type == eventDisconnect && e.Stats != nil {
+			no.Score = sessionScore(e.Stats, e.Duration)
+		}
+		n.add(no)
+	})
+	go n.run()
+}
+
+func (n *notifier) add(no notification) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.cfg.MaxBatch > 0 && len(n.batch) >= n.cfg.MaxBatch {
+		n.dropped++
+		return
+	}
+	n.batch = append(n.batch, no)
+}
+
+// run sends the batch every interval, unless it is empty or the sink already
+// sent too many messages. It never returns.
+func (n *notifier) run() {
+	for range time.Tick(time.Duration(n.cfg.Interval)) {
+		n.mu.Lock()
+		if len(n.batch) == 0 || !n.limiter.Allow() {
+			n.mu.Unlock()
+			continue
+		}
+		batch, dropped := n.batch, n.dropped
+		n.batch, n.dropped = nil, 0
+		n.mu.Unlock()
+		if err := n.send(batch, dropped); err != nil {
+			log.Error("Could not send notifications", "sink", n.name, "error", err)
+			notificationsSent.WithLabelValues(n.name, "error").Add(float64(len(batch)))
+			continue
+		}
+		notificationsSent.WithLabelValues(n.name, "sent").Add(float64(len(batch)))
+	}
+}
+
+// notifyClient sends the notifications, a sink that hangs must not hold up
+// the next batch for long.
+var notifyClient = &http.Client{Timeout: 10 * time.Second}
+
+// postJSON posts body as JSON to url, a status other than 2xx is an error.
+func postJSON(url string, body any) error {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	return post(url, http.Header{"Content-Type": {"application/json"}}, b)
+}
+
+// post posts body to url with header, a status other than 2xx is an error.
+func post(url string, header http.Header, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header = header
+	resp, err := notifyClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+// truncate cuts s to at most n bytes, marking the cut with an ellipsis.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	s = s[:n-len("…")]
+	for !utf8.ValidString(s) {
+		s = s[:len(s)-1]
+	}
+	return s + "…"
+}
+
+// visitsOf counts the recorded visits of ip.
+func (s *store) visitsOf(ip string) (int, error) {
+	var n int
+	err := s.db.QueryRow(`SELECT COUNT(*) FROM visits WHERE ip = ?`, ip).Scan(&n)
+	return n, err
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ntfyConfig pushes the events of the chosen types to the phone of the
+// operator through ntfy.
+type ntfyConfig struct {
+	// TopicURL is the URL of the topic, like https://ntfy.sh/mytopic,
+	// disabled when empty.
+	TopicURL string `json:"topic_url"`
+	// Token is the access token of protected topics.
+	Token string `json:"token"`
+	// Events are the event types to push, see notifyEvents. Disconnects are
+	// only pushed for sessions with a sessionScore of at least HumanScore.
+	Events     []string `json:"events"`
+	HumanScore int      `json:"human_score"`
+	// Priorities are the ntfy priorities from 1 (min) to 5 (max) by event
+	// type, the others get 3. A message gets the highest one of its events.
+	Priorities map[string]int `json:"priorities"`
+	// QuietHours push at priority min, which doesn't make a sound.
+	QuietHours quietHours `json:"quiet_hours"`
+	// Templates override the messages of event types, as text/template
+	// executed with the notification.
+	Templates map[string]string `json:"templates"`
+	Batch     notifyConfig      `json:"batch"`
+}
+
+// quietHours are the time of day from From to To, they may span midnight.
+// They are disabled when From and To are the same.
+type quietHours struct {
+	From clockTime `json:"from"`
+	To   clockTime `json:"to"`
+}
+
+// contains reports whether t is within the quiet hours.
+func (q quietHours) contains(t time.Time) bool {
+	c := clockOf(t)
+	if q.From <= q.To {
+		return q.From <= c && c < q.To
+	}
+	return c >= q.From || c < q.To
+}
+
+const (
+	ntfyMin     = 1
+	ntfyDefault = 3
+	ntfyMax     = 5
+)
+
+// subscribeNtfySink pushes a message with a line for every event of the types
+// cfg selects.
+func subscribeNtfySink(bus *eventBus, db *store, cfg ntfyConfig) error {
+	templates, err := parseTemplates("ntfy", cfg.Templates)
+	if err != nil {
+		return err
+	}
+	selected := eventFilter("ntfy", cfg.Events)
+	want := func(e event) bool {
+		if e.Type == eventDisconnect && (e.Stats == nil || sessionScore(e.Stats, e.Duration) < cfg.HumanScore) {
+			return false
+		}
+		return selected(e)
+	}
+	subscribeNotifier(bus, db, "ntfy", cfg.Batch, want, func(batch []notification, dropped int) error {
+		text, err := templates.render(batch, dropped)
+		if err != nil {
+			return err
+		}
+		header := http.Header{
+			"Title":    {"get-pwned-bozo"},
+			"Tags":     {"clown_face"},
+			"Priority": {strconv.Itoa(cfg.priority(batch, time.Now()))},
+		}
+		if cfg.Token != "" {
+			header.Set("Authorization", "Bearer "+cfg.Token)
+		}
+		return post(cfg.TopicURL, header, []byte(text))
+	})
+	return nil
+}
+
+// priority is the highest priority of the events of batch, or min during the
+// quiet hours.
+func (c ntfyConfig) priority(batch []notification, now time.Time) int {
+	if c.QuietHours.contains(now) {
+		return ntfyMin
+	}
+	priority := ntfyMin
+	for _, n := range batch {
+		p, ok := c.Priorities[string(n.Type)]
+		if !ok {
+			p = ntfyDefault
+		}
+		priority = max(priority, min(max(p, ntfyMin), ntfyMax))
+	}
+	return priority
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/ssh"
+)
+
+const sessionCountryKey contextKey = "sessionCountry"
+
+// sessionCountry returns the ISO country code of the visitor, if GeoIP knows
+// it.
+func sessionCountry(ctx ssh.Context) string {
+	country, _ := ctx.Value(sessionCountryKey).(string)
+	return country
+}
+
+// countryFlag returns the flag emoji of an ISO country code.
+func countryFlag(country string) string {
+	if len(country) != 2 || country[0] < 'A' || country[0] > 'Z' || country[1] < 'A' || country[1] > 'Z' {
+		return ""
+	}
+	return string([]rune{rune(country[0]-'A') + 0x1F1E6, rune(country[1]-'A') + 0x1F1E6})
+}
+
+// maxOnlineFlags is the number of countries listed in the online panel.
+const maxOnlineFlags = 5
+
+// onlineView is the panel telling visitors who else is online.
+func (m model) onlineView() string {
+	others := m.online.total - 1
+	if others <= 0 {
+		return translate(m.lang, "You're the only bozo here.")
+	}
+	countries := maps.Clone(m.online.countries)
+	countries[m.country]--
+	codes := make([]string, 0, len(countries))
+	for code := range countries {
+		codes = append(codes, code)
+	}
+	slices.SortFunc(codes, func(a, b string) int {
+		return cmp.Or(cmp.Compare(countries[b], countries[a]), cmp.Compare(a, b))
+	})
+	var flags []string
+	for _, code := range codes {
+		if f := countryFlag(code); f != "" && countries[code] > 0 && len(flags) < maxOnlineFlags {
+			flags = append(flags, fmt.Sprintf("%s %d", f, countries[code]))
+		}
+	}
+	msg := fmt.Sprintf(translate(m.lang, "%d other bozos online"), others)
+	if others == 1 {
+		msg = translate(m.lang, "1 other bozo online")
+	}
+	if len(flags) > 0 {
+		msg += ": " + strings.Join(flags, " ")
+	}
+	return msg
+}
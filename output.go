@@ -0,0 +1,52 @@
+package main
+
+import (
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// frameBudget is how long writing a frame may take, a client that can't keep
+// up with 10 frames a second is backed up.
+const frameBudget = 100 * time.Millisecond
+
+// lockedWriter serializes writes to the session, so the escape sequences the
+// model sends besides the frames never end up in the middle of one. It keeps
+// track of how long the writes take, so View can skip frames for clients
+// that don't keep up.
+type lockedWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+
+	// writing is when the write in progress started, last how long the
+	// previous one took and when it ended, all in Unix nanoseconds.
+	writing atomic.Int64
+	last    atomic.Int64
+	ended   atomic.Int64
+}
+
+func (w *lockedWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	start := time.Now()
+	w.writing.Store(start.UnixNano())
+	n, err := w.w.Write(p)
+	end := time.Now()
+	w.last.Store(int64(end.Sub(start)))
+	w.ended.Store(end.UnixNano())
+	w.writing.Store(0)
+	return n, err
+}
+
+// backedUp reports whether the client is still busy with the output. That is
+// while a write takes longer than the frame budget, and after a slow write
+// for as long as it took, so the frame rate follows what the client manages.
+func (w *lockedWriter) backedUp() bool {
+	now := time.Now().UnixNano()
+	if writing := w.writing.Load(); writing != 0 && now-writing > int64(frameBudget) {
+		return true
+	}
+	last := w.last.Load()
+	return last > int64(frameBudget) && now-w.ended.Load() < last
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// page is a child screen of the root model. The root model keeps the state
+// all pages share and hands itself to the page it shows.
+type page interface {
+	// title is the name of the page in the tab bar.
+	title() string
+	// name is the action toggling the page in the keymap, pages without a
+	// binding are only reached by tab or number.
+	name() string
+	// open is called whenever the page is switched to.
+	open(m model) (page, tea.Cmd)
+	// update gets the input while the page is shown, and every other message
+	// at any time.
+	update(m model, msg tea.Msg) (page, tea.Cmd)
+	view(m model) string
+}
+
+// newPages returns the pages in tab order, only admins get the stats page.
+func newPages(admin bool) []page {
+	pages := []page{bannerPage{}}
+	if admin {
+		pages = append(pages, statsPage{})
+	}
+	return append(pages, chatPage{}, &leaderboardPage{}, guestbookPage{}, &eventsPage{}, galleryPage{}, settingsPage{}, aboutPage{}, &helpPage{})
+}
+
+// show switches to the page at index i.
+func (m model) show(i int) (model, tea.Cmd) {
+	m.page = i
+	var cmd tea.Cmd
+	m.pages[i], cmd = m.pages[i].open(m)
+	return m, cmd
+}
+
+// toggle switches to the page of name, or back to the banner if it is already
+// shown.
+func (m model) toggle(name string) (model, tea.Cmd) {
+	for i, p := range m.pages {
+		if p.name() != name {
+			continue
+		}
+		if m.page == i {
+			return m.show(0)
+		}
+		return m.show(i)
+	}
+	return m, nil
+}
+
+// navigate handles the keys switching pages, it reports whether msg was one
+// of them.
+func (m model) navigate(msg tea.KeyMsg) (model, tea.Cmd, bool) {
+	switch {
+	case m.keys.matches(msg, "next_page"):
+		m, cmd := m.show((m.page + 1) % len(m.pages))
+		return m, cmd, true
+	case m.keys.matches(msg, "prev_page"):
+		m, cmd := m.show((m.page + len(m.pages) - 1) % len(m.pages))
+		return m, cmd, true
+	default:
+		if n, err := strconv.Atoi(msg.String()); err == nil && n >= 1 && n <= m.numbered() {
+			m, cmd := m.show(n - 1)
+			return m, cmd, true
+		}
+		for _, p := range m.pages {
+			if m.keys.matches(msg, p.name()) {
+				m, cmd := m.toggle(p.name())
+				return m, cmd, true
+			}
+		}
+	}
+	return m, nil, false
+}
+
+// updatePage passes msg to the page that is shown.
+func (m model) updatePage(msg tea.Msg) (model, tea.Cmd) {
+	var cmd tea.Cmd
+	m.pages[m.page], cmd = m.pages[m.page].update(m, msg)
+	return m, cmd
+}
+
+// updatePages passes msg to all pages.
+func (m model) updatePages(msg tea.Msg) (model, tea.Cmd) {
+	cmds := make([]tea.Cmd, len(m.pages))
+	for i, p := range m.pages {
+		m.pages[i], cmds[i] = p.update(m, msg)
+	}
+	return m, tea.Batch(cmds...)
+}
+
+// numbered is how many pages have a number key. The help page comes last and
+// has a key of its own, so the numbers don't run out for admins.
+func (m model) numbered() int {
+	if _, ok := m.pages[len(m.pages)-1].(*helpPage); ok {
+		return len(m.pages) - 1
+	}
+	return len(m.pages)
+}
+
+// tabBar lists the pages with their keys, the one shown is highlighted.
+func (m model) tabBar() string {
+	tabs := make([]string, len(m.pages))
+	for i, p := range m.pages {
+		tab := fmt.Sprintf("%d %s", i+1, p.title())
+		if i >= m.numbered() {
+			tab = strings.TrimSpace(m.keys.first(p.name()) + " " + p.title())
+		}
+		if i == m.page {
+			tabs[i] = m.txtStyle.Render(tab)
+		} else {
+			tabs[i] = m.quitStyle.Render(tab)
+		}
+	}
+	hint := m.keys.hint("quit", "ctrl+c") + " to quit"
+	if next := m.keys.first("next_page"); next != "" {
+		hint = next + " to switch, " + hint
+	}
+	return strings.Join(tabs, buttonGap) + m.quitStyle.Render(buttonGap+hint)
+}
+
+// maxNameLength is how long the name of a visitor may be.
+const maxNameLength = 24
+
+// nameMsg is the name the visitor typed in.
+type nameMsg string
+
+// bannerPage is the animated banner. The name key asks for the name of the
+// visitor below it.
+type bannerPage struct {
+	prompt textinput.Model
+}
+
+func (bannerPage) title() string                { return "Banner" }
+func (bannerPage) name() string                 { return "banner" }
+func (p bannerPage) open(model) (page, tea.Cmd) { return p, nil }
+func (p bannerPage) typing() bool               { return p.prompt.Focused() }
+
+func (p bannerPage) update(m model, msg tea.Msg) (page, tea.Cmd) {
+	if !p.prompt.Focused() {
+		if msg, ok := msg.(tea.KeyMsg); ok && m.keys.matches(msg, "name") {
+			p.prompt = textinput.New()
+			p.prompt.Prompt = "What's your name, bozo? "
+			p.prompt.CharLimit = maxNameLength
+			p.prompt.SetValue(m.name)
+			return p, p.prompt.Focus()
+		}
+		return p, nil
+	}
+	if msg, ok := msg.(tea.KeyMsg); ok {
+		switch msg.String() {
+		case "esc":
+			p.prompt.Blur()
+			return p, nil
+		case "enter":
+			p.prompt.Blur()
+			name := sanitize(p.prompt.Value(), maxNameLength)
+			if name == "" {
+				return p, nil
+			}
+			return p, func() tea.Msg { return nameMsg(name) }
+		}
+	}
+	var cmd tea.Cmd
+	p.prompt, cmd = p.prompt.Update(msg)
+	return p, cmd
+}
+
+func (p bannerPage) view(m model) string {
+	if p.prompt.Focused() {
+		return m.bannerView() + p.prompt.View() + "\n"
+	}
+	return m.bannerView()
+}
+
+// statsPage shows the stats of the server, refreshed every frame.
+type statsPage struct{}
+
+func (statsPage) title() string                           { return "Stats" }
+func (statsPage) name() string                            { return "stats" }
+func (p statsPage) open(model) (page, tea.Cmd)            { return p, nil }
+func (p statsPage) update(model, tea.Msg) (page, tea.Cmd) { return p, nil }
+
+func (statsPage) view(m model) string {
+	total, unique := m.server.visitors.counts()
+	msg := fmt.Sprintf(
+		"Visitors today:  %d\nVisitors total:  %d (%d unique)\nActive sessions: %d\nServer uptime:   %v",
+		m.server.visitorsToday(),
+		total,
+		unique,
+		m.server.active.Load(),
+		m.server.uptime().Truncate(time.Second),
+	)
+	return m.lolcat("Stats\n") + "\n" + m.txtStyle.Render(msg) + "\n"
+}
+
+const aboutText = `This is get-pwned-bozo, a just-for-fun ssh server. There is
+nothing to hack here: everyone gets in, stares at a rainbow and
+gets told they got pwned. Your visit is counted and may end up
+on the leaderboard, masked so nobody can tell it was you.`
+
+// aboutPage tells visitors what this server is.
+type aboutPage struct{}
+
+func (aboutPage) title() string                           { return "About" }
+func (aboutPage) name() string                            { return "about" }
+func (p aboutPage) open(model) (page, tea.Cmd)            { return p, nil }
+func (p aboutPage) update(model, tea.Msg) (page, tea.Cmd) { return p, nil }
+
+func (aboutPage) view(m model) string {
+	total, _ := m.server.visitors.counts()
+	msg := fmt.Sprintf("%s\n\nUp for %v and %d bozos got pwned so far.",
+		aboutText, m.server.uptime().Truncate(time.Second), total)
+	return m.lolcat("About\n") + "\n" + m.txtStyle.Render(msg) + "\n"
+}
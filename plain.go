@@ -0,0 +1,23 @@
+package main
+
+// defaultBannerDescription stands in for the usual graphic in plain mode.
+const defaultBannerDescription = "A braille drawing next to the words Get Pwned Bozo in big letters.\n"
+
+// bannerDescription is what plain mode shows instead of the banner graphic,
+// screen readers can't make sense of the art.
+func (m model) bannerDescription() string {
+	switch {
+	case m.konamiActive():
+		return "The Konami code worked, the banner glitches.\n"
+	case m.banner == graphic:
+		return defaultBannerDescription
+	default:
+		return "A banner graphic made of text.\n"
+	}
+}
+
+// still reports whether nothing may move on its own, with reduced motion or in
+// plain mode.
+func (m model) still() bool {
+	return m.reducedMotion || m.plain
+}
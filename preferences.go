@@ -0,0 +1,212 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/log"
+)
+
+// animationSpeed scales how fast the hue moves every tick.
+type animationSpeed struct {
+	name   string
+	factor float64
+}
+
+// animationSpeeds are the speeds visitors can pick from, normal is the
+// default.
+var animationSpeeds = []animationSpeed{
+	{"slow", 0.5},
+	{"normal", 1},
+	{"fast", 2},
+}
+
+const defaultSpeed = 1
+
+// preferences are the settings a visitor picked, they are kept for the next
+// visit by the fingerprint of the key the client offered.
+type preferences struct {
+	Theme         string
+	Speed         string
+	ReducedMotion bool
+}
+
+func (s *store) preferences(fingerprint string) (preferences, bool, error) {
+	var p preferences
+	err := s.db.QueryRow(
+		`SELECT theme, speed, reduced_motion FROM preferences WHERE fingerprint = ?`,
+		fingerprint,
+	).Scan(&p.Theme, &p.Speed, &p.ReducedMotion)
+	if errors.Is(err, sql.ErrNoRows) {
+		return p, false, nil
+	}
+	return p, err == nil, err
+}
+
+func (s *store) savePreferences(fingerprint string, p preferences) error {
+	_, err := s.db.Exec(
+		`INSERT INTO preferences (fingerprint, theme, speed, reduced_motion) VALUES (?, ?, ?, ?)
+		ON CONFLICT (fingerprint) DO UPDATE SET theme = excluded.theme, speed = excluded.speed, reduced_motion = excluded.reduced_motion`,
+		fingerprint, p.Theme, p.Speed, p.ReducedMotion,
+	)
+	return err
+}
+
+// preferencesMsg carries the preferences loaded for the visitor.
+type preferencesMsg preferences
+
+// setPreferencesMsg carries preferences the visitor changed, they are applied
+// and saved.
+type setPreferencesMsg preferences
+
+// loadPreferences restores the preferences of the key with fingerprint in the
+// background.
+func loadPreferences(db *store, fingerprint string) tea.Cmd {
+	if fingerprint == "" {
+		return nil
+	}
+	return func() tea.Msg {
+		p, ok, err := db.preferences(fingerprint)
+		if err != nil {
+			log.Error("Could not load preferences", "error", err)
+		}
+		if !ok {
+			return nil
+		}
+		return preferencesMsg(p)
+	}
+}
+
+// preferences returns the settings of the session.
+func (m model) preferences() preferences {
+	return preferences{
+		Theme:         themes[m.theme].name,
+		Speed:         animationSpeeds[m.speed].name,
+		ReducedMotion: m.reducedMotion,
+	}
+}
+
+// applyPreferences changes the settings of the session to p, unknown themes
+// and speeds are left alone.
+func (m model) applyPreferences(p preferences) model {
+	if i := slices.IndexFunc(themes, func(t theme) bool { return t.name == p.Theme }); i >= 0 {
+		m.theme = i
+		m.color = themes[i].color(m.color.Hue())
+	}
+	if i := slices.IndexFunc(animationSpeeds, func(s animationSpeed) bool { return s.name == p.Speed }); i >= 0 {
+		m.speed = i
+	}
+	m.reducedMotion = p.ReducedMotion
+	return m
+}
+
+// savePreferences saves the settings of the session in the background, if the
+// client logged in with a key to keep them by. The settings of keys that were
+// only offered are read-only, the client might not own them.
+func (m model) savePreferences() tea.Cmd {
+	if !m.keyVerified {
+		return nil
+	}
+	db, fingerprint, p := m.db, m.offeredKey, m.preferences()
+	return m.guard.cmd(func() tea.Msg {
+		if err := db.savePreferences(fingerprint, p); err != nil {
+			log.Error("Could not save preferences", "error", err)
+		}
+		return nil
+	}, nil)
+}
+
+// settings are the rows of the settings page.
+var settings = []struct {
+	name string
+	// change returns p with the setting moved by delta, 1 or -1.
+	change func(p preferences, delta int) preferences
+	value  func(p preferences) string
+}{
+	{
+		name: "Theme",
+		change: func(p preferences, delta int) preferences {
+			i := slices.IndexFunc(themes, func(t theme) bool { return t.name == p.Theme })
+			p.Theme = themes[(i+delta+len(themes))%len(themes)].name
+			return p
+		},
+		value: func(p preferences) string { return p.Theme },
+	},
+	{
+		name: "Animation speed",
+		change: func(p preferences, delta int) preferences {
+			i := slices.IndexFunc(animationSpeeds, func(s animationSpeed) bool { return s.name == p.Speed })
+			p.Speed = animationSpeeds[min(max(i+delta, 0), len(animationSpeeds)-1)].name
+			return p
+		},
+		value: func(p preferences) string { return p.Speed },
+	},
+	{
+		name: "Reduced motion",
+		change: func(p preferences, _ int) preferences {
+			p.ReducedMotion = !p.ReducedMotion
+			return p
+		},
+		value: func(p preferences) string {
+			if p.ReducedMotion {
+				return "on"
+			}
+			return "off"
+		},
+	},
+}
+
+// settingsPage lets visitors change their preferences.
+type settingsPage struct {
+	cursor int
+}
+
+func (settingsPage) title() string                { return "Settings" }
+func (settingsPage) name() string                 { return "settings" }
+func (p settingsPage) open(model) (page, tea.Cmd) { return p, nil }
+
+func (p settingsPage) update(m model, msg tea.Msg) (page, tea.Cmd) {
+	key, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return p, nil
+	}
+	delta := 0
+	switch key.String() {
+	case "up", "k":
+		p.cursor = (p.cursor + len(settings) - 1) % len(settings)
+	case "down", "j":
+		p.cursor = (p.cursor + 1) % len(settings)
+	case "right", "enter", " ":
+		delta = 1
+	case "left":
+		delta = -1
+	}
+	if delta == 0 {
+		return p, nil
+	}
+	prefs := settings[p.cursor].change(m.preferences(), delta)
+	return p, func() tea.Msg { return setPreferencesMsg(prefs) }
+}
+
+func (p settingsPage) view(m model) string {
+	prefs := m.preferences()
+	b := strings.Builder{}
+	for i, s := range settings {
+		cursor := "  "
+		if i == p.cursor {
+			cursor = "> "
+		}
+		fmt.Fprintf(&b, "%s%s< %s >\n", cursor, padRight(s.name, 18), s.value(prefs))
+	}
+	b.WriteString("\nj/k to pick a setting, left/right to change it.\n")
+	if m.keyVerified {
+		b.WriteString("Your settings are kept for the next visit with this key.\n")
+	} else {
+		b.WriteString("Your settings are only kept for keys you log in with.\n")
+	}
+	return m.lolcat("Settings\n") + "\n" + m.txtStyle.Render(b.String())
+}
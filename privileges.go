@@ -0,0 +1,11 @@
+package main
+
+// runAsConfig is the unprivileged user the server switches to once it bound
+// its listeners, so it can be started as root to listen on port 22.
+type runAsConfig struct {
+	// User is the name of the user, the privileges are kept when empty.
+	User string `json:"user"`
+	// Group is the name of the group, the primary group of User is used when
+	// empty.
+	Group string `json:"group"`
+}
@@ -0,0 +1,14 @@
+//go:build !unix
+
+package main
+
+import "errors"
+
+// dropPrivileges fails if cfg names a user, users can only be switched on
+// unix.
+func dropPrivileges(cfg runAsConfig) error {
+	if cfg.User == "" {
+		return nil
+	}
+	return errors.New("run_as is only supported on unix")
+}
@@ -0,0 +1,61 @@
+//go:build unix
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"strconv"
+
+	"github.com/charmbracelet/log"
+	"golang.org/x/sys/unix"
+)
+
+// dropPrivileges switches the process to the user and group of cfg. The files
+// the server writes later, like the visitor counter, have to be writable by
+// that user.
+func dropPrivileges(cfg runAsConfig) error {
+	if cfg.User == "" {
+		return nil
+	}
+	if os.Getuid() != 0 {
+		return errors.New("not running as root")
+	}
+	u, err := user.Lookup(cfg.User)
+	if err != nil {
+		return err
+	}
+	uid, err := strconv.Atoi(u.Uid)
+	if err != nil {
+		return fmt.Errorf("invalid uid %q: %w", u.Uid, err)
+	}
+	gidStr := u.Gid
+	if cfg.Group != "" {
+		g, err := user.LookupGroup(cfg.Group)
+		if err != nil {
+			return err
+		}
+		gidStr = g.Gid
+	}
+	gid, err := strconv.Atoi(gidStr)
+	if err != nil {
+		return fmt.Errorf("invalid gid %q: %w", gidStr, err)
+	}
+	// The group has to go first, without root it can't be changed anymore.
+	if err := unix.Setgroups([]int{gid}); err != nil {
+		return fmt.Errorf("setgroups: %w", err)
+	}
+	if err := unix.Setgid(gid); err != nil {
+		return fmt.Errorf("setgid: %w", err)
+	}
+	if err := unix.Setuid(uid); err != nil {
+		return fmt.Errorf("setuid: %w", err)
+	}
+	if unix.Setuid(0) == nil {
+		return errors.New("could regain root after dropping privileges")
+	}
+	log.Info("Dropped privileges", "user", cfg.User, "uid", uid, "gid", gid)
+	return nil
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/charmbracelet/ssh"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"golang.org/x/time/rate"
+)
+
+// rateLimitConfig configures token bucket rate limiting of new connections,
+// per source IP and per subnet (/24 for IPv4, /48 for IPv6).
+type rateLimitConfig struct {
+	// Rate is the sustained connections per second allowed per IP, 0
+	// disables the per-IP limit.
+	Rate  float64 `json:"rate"`
+	Burst int     `json:"burst"`
+	// SubnetRate is the sustained connections per second allowed per subnet,
+	// 0 disables the per-subnet limit.
+	SubnetRate  float64 `json:"subnet_rate"`
+	SubnetBurst int     `json:"subnet_burst"`
+	// Delay is how long the first connection over the limit is held before
+	// the handshake. Every further violation doubles it, up to MaxDelay.
+	Delay    duration `json:"delay"`
+	MaxDelay duration `json:"max_delay"`
+	// DropAfter is the number of consecutive violations after which
+	// connections are dropped instead of delayed.
+	DropAfter int `json:"drop_after"`
+}
+
+var rateLimited = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "bozo_rate_limited_total",
+	Help: "Connections that were over the rate limit.",
+}, []string{"action"})
+
+type limitedSource struct {
+	limiter    *rate.Limiter
+	violations int
+	lastSeen   time.Time
+}
+
+// connLimiter rate limits connections before the ssh handshake, so limited
+// sources never get a PTY allocated.
+type connLimiter struct {
+	cfg rateLimitConfig
+	bus *eventBus
+
+	mu      sync.Mutex
+	ips     map[string]*limitedSource
+	subnets map[string]*limitedSource
+}
+
+func newConnLimiter(cfg rateLimitConfig, bus *eventBus) *connLimiter {
+	return &connLimiter{
+		cfg:     cfg,
+		bus:     bus,
+		ips:     make(map[string]*limitedSource),
+		subnets: make(map[string]*limitedSource),
+	}
+}
+
+func subnet(ip net.IP) string {
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4.Mask(net.CIDRMask(24, 32)).String() + "/24"
+	}
+	return ip.Mask(net.CIDRMask(48, 128)).String() + "/48"
+}
+
+// take takes a token from the bucket of key, creating it if needed, and
+// returns the number of consecutive violations of the source.
+func take(sources map[string]*limitedSource, key string, r float64, burst int, now time.Time) int {
+	src, ok := sources[key]
+	if !ok {
+		src = &limitedSource{limiter: rate.NewLimiter(rate.Limit(r), burst)}
+		sources[key] = src
+	}
+	src.lastSeen = now
+	if src.limiter.AllowN(now, 1) {
+		src.violations = 0
+	} else {
+		src.violations++
+	}
+	return src.violations
+}
+
+// check returns how long a new connection from ip has to wait, or whether it
+// has to be dropped.
+func (l *connLimiter) check(ip net.IP) (time.Duration, bool) {
+	now := time.Now()
+	l.mu.Lock()
+	violations := 0
+	if l.cfg.Rate > 0 {
+		violations = take(l.ips, ip.String(), l.cfg.Rate, l.cfg.Burst, now)
+	}
+	if l.cfg.SubnetRate > 0 {
+		violations = max(violations, take(l.subnets, subnet(ip), l.cfg.SubnetRate, l.cfg.SubnetBurst, now))
+	}
+	l.mu.Unlock()
+
+	if violations == 0 {
+		return 0, false
+	}
+	if l.cfg.DropAfter > 0 && violations >= l.cfg.DropAfter {
+		return 0, true
+	}
+	delay := time.Duration(l.cfg.Delay) << (violations - 1)
+	if delay > time.Duration(l.cfg.MaxDelay) || delay <= 0 {
+		delay = time.Duration(l.cfg.MaxDelay)
+	}
+	return delay, false
+}
+
+// connCallback delays or drops connections of sources over the limit.
+func (l *connLimiter) connCallback(_ ssh.Context, conn net.Conn) net.Conn {
+	ip := remoteIP(conn.RemoteAddr())
+	if ip == nil {
+		return conn
+	}
+	delay, drop := l.check(ip)
+	switch {
+	case drop:
+		rateLimited.WithLabelValues("drop").Inc()
+		l.bus.publish(event{Type: eventAbuse, IP: ip, Reason: "rate_limit_drop"})
+		return nil
+	case delay > 0:
+		rateLimited.WithLabelValues("delay").Inc()
+		l.bus.publish(event{Type: eventAbuse, IP: ip, Reason: "rate_limit_delay"})
+		log.Debug("Delaying rate limited connection", "remote", ip, "delay", delay)
+		time.Sleep(delay)
+	}
+	return conn
+}
+
+// cleanup forgets sources that haven't been seen for a while, their buckets
+// are full again anyway. It never returns.
+func (l *connLimiter) cleanup() {
+	for range time.Tick(time.Minute) {
+		cutoff := time.Now().Add(-10 * time.Minute)
+		l.mu.Lock()
+		for _, sources := range []map[string]*limitedSource{l.ips, l.subnets} {
+			for key, src := range sources {
+				if src.lastSeen.Before(cutoff) {
+					delete(sources, key)
+				}
+			}
+		}
+		l.mu.Unlock()
+	}
+}
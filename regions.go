@@ -0,0 +1,28 @@
+package main
+
+import "github.com/charmbracelet/lipgloss"
+
+// regions are the parts of the banner screen besides the banner itself, as
+// they were last rendered. They rarely change, so unlike the banner they are
+// only styled again when their text did. The model keeps the map, so all
+// copies of it share the regions.
+type regions map[string]region
+
+type region struct {
+	text     string
+	rendered string
+}
+
+// render returns text of the region name rendered in style, reusing the last
+// rendering if the text is the same.
+func (r regions) render(name string, style lipgloss.Style, text string) string {
+	if r == nil {
+		return style.Render(text)
+	}
+	if c, ok := r[name]; ok && c.text == text {
+		return c.rendered
+	}
+	rendered := style.Render(text)
+	r[name] = region{text, rendered}
+	return rendered
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/log"
+	"github.com/charmbracelet/ssh"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// regular is a visitor recognized by the public key their client offered.
+type regular struct {
+	Fingerprint string
+	Nickname    string
+	FirstSeen   time.Time
+	LastSeen    time.Time
+	Visits      int
+}
+
+var (
+	nicknameAdjectives = []string{
+		"Sneaky", "Grumpy", "Sleepy", "Shiny", "Wobbly", "Spicy", "Fluffy", "Cranky",
+		"Dizzy", "Soggy", "Nosy", "Jolly", "Zesty", "Clumsy", "Fancy", "Salty",
+	}
+	nicknameNouns = []string{
+		"Bozo", "Clown", "Goblin", "Potato", "Penguin", "Gremlin", "Noodle", "Walrus",
+		"Pickle", "Muffin", "Raccoon", "Pigeon", "Toaster", "Llama", "Wombat", "Kraken",
+	}
+)
+
+// nickname derives the nickname of a regular from the fingerprint of their
+// key, so it is the same even if the database is lost.
+func nickname(fingerprint string) string {
+	h := sha256.Sum256([]byte(fingerprint))
+	return nicknameAdjectives[int(h[0])%len(nicknameAdjectives)] + " " + nicknameNouns[int(h[1])%len(nicknameNouns)]
+}
+
+// visitRegular records a visit of the key with fingerprint and returns the
+// regular as they were before this visit. Visits is 0 for first-time visitors.
+func (s *store) visitRegular(fingerprint string, now time.Time) (regular, error) {
+	r := regular{Fingerprint: fingerprint}
+	var first, last int64
+	err := s.db.QueryRow(
+		`SELECT nickname, first_seen, last_seen, visits FROM regulars WHERE fingerprint = ?`,
+		fingerprint,
+	).Scan(&r.Nickname, &first, &last, &r.Visits)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		r.Nickname = nickname(fingerprint)
+		_, err = s.db.Exec(
+			`INSERT INTO regulars (fingerprint, nickname, first_seen, last_seen, visits) VALUES (?, ?, ?, ?, 1)`,
+			fingerprint, r.Nickname, now.Unix(), now.Unix(),
+		)
+		return r, err
+	case err != nil:
+		return r, err
+	}
+	r.FirstSeen = time.Unix(first, 0)
+	r.LastSeen = time.Unix(last, 0)
+	_, err = s.db.Exec(
+		`UPDATE regulars SET last_seen = ?, visits = visits + 1 WHERE fingerprint = ?`,
+		now.Unix(), fingerprint,
+	)
+	return r, err
+}
+
+const offeredKeyKey contextKey = "offeredKey"
+
+// rememberOfferedKey stores the fingerprint of the first plain public key the
+// client offered. Clients offer their keys before falling back to the other
+// methods, so regulars are recognized even though only admins get in with a
+// key. The key is not verified to belong to the client, it is only good for a
+// greeting.
+func rememberOfferedKey(ctx ssh.Context, key ssh.PublicKey) {
+	if _, ok := key.(*gossh.Certificate); ok {
+		return
+	}
+	if _, ok := ctx.Value(offeredKeyKey).(string); !ok {
+		ctx.SetValue(offeredKeyKey, gossh.FingerprintSHA256(key))
+	}
+}
+
+// offeredKey returns the fingerprint of the key the client offered first, if
+// it offered one.
+func offeredKey(ctx ssh.Context) string {
+	fp, _ := ctx.Value(offeredKeyKey).(string)
+	return fp
+}
+
+const verifiedKeyKey contextKey = "verifiedKey"
+
+// rememberVerifiedKey stores the fingerprint of the plain public key the
+// client completed publickey auth with. Unlike the offered key it belongs to
+// the client.
+func rememberVerifiedKey(ctx ssh.Context) {
+	key, ok := ctx.Value(ssh.ContextKeyPublicKey).(ssh.PublicKey)
+	if !ok {
+		return
+	}
+	if _, ok := key.(*gossh.Certificate); !ok {
+		ctx.SetValue(verifiedKeyKey, gossh.FingerprintSHA256(key))
+	}
+}
+
+// verifiedKey returns the fingerprint of the key the client authenticated
+// with, if it did.
+func verifiedKey(ctx ssh.Context) string {
+	fp, _ := ctx.Value(verifiedKeyKey).(string)
+	return fp
+}
+
+type regularMsg regular
+
+// loadRegular records the visit of the regular with fingerprint in the
+// background and greets them once it is done.
+func loadRegular(db *store, fingerprint string) tea.Cmd {
+	if fingerprint == "" {
+		return nil
+	}
+	return func() tea.Msg {
+		r, err := db.visitRegular(fingerprint, time.Now())
+		if err != nil {
+			log.Error("Could not record regular", "error", err)
+			return nil
+		}
+		return regularMsg(r)
+	}
+}
+
+// greeting is the line greeting a regular on the banner.
+func (m model) greeting() string {
+	if m.regular.Nickname == "" {
+		return ""
+	}
+	if m.regular.Visits == 0 {
+		return fmt.Sprintf(translate(m.lang, "Nice key, %s. We'll remember you."), m.regular.Nickname)
+	}
+	return fmt.Sprintf(translate(m.lang, "Welcome back, %s! Last visit: %s."), m.regular.Nickname, m.regular.LastSeen.Format(time.DateOnly))
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/charmbracelet/log"
+)
+
+// handleReloadSignals reloads the config on SIGHUP and applies the parts that
+// can change at runtime, the host keys and the admin keys. Reloads are recorded in the
+// audit log. It never returns.
+func handleReloadSignals(audit *auditLog, keys *hostKeys, auth *auth) {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGHUP)
+	for range sig {
+		cfg, err := loadConfig(*configPath)
+		if err != nil {
+			log.Error("Could not reload config", "error", err)
+			continue
+		}
+		if err := keys.reload(cfg.HostKeys); err != nil {
+			log.Error("Could not reload host keys", "error", err)
+		}
+		if err := auth.reload(cfg.Auth); err != nil {
+			log.Error("Could not reload admin keys", "error", err)
+		}
+		if err := audit.record("signal:SIGHUP", "reload-config", *configPath); err != nil {
+			log.Error("Could not write audit log", "error", err)
+		}
+	}
+}
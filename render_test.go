@@ -0,0 +1,64 @@
+package main
+
+import (
+	"io"
+	"net"
+	"testing"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+)
+
+// benchModel is a session on the banner page, as View sees it once the
+// lookups are done.
+func benchModel(profile termenv.Profile) model {
+	r := lipgloss.NewRenderer(io.Discard)
+	r.SetColorProfile(profile)
+	return model{
+		address:    &net.TCPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 22},
+		width:      120,
+		height:     40,
+		color:      themes[0].color(0),
+		style:      r.NewStyle(),
+		txtStyle:   r.NewStyle().Foreground(lipgloss.Color("10")),
+		quitStyle:  r.NewStyle().Foreground(lipgloss.Color("8")),
+		stats:      &sessionStats{},
+		banner:     graphic,
+		profile:    profile,
+		frames:     &frameTimer{},
+		sessionLog: newSessionLog(),
+		regions:    make(regions),
+		out:        &lockedWriter{w: io.Discard},
+		pages:      newPages(false),
+		keys:       newKeyMap(nil),
+		speed:      defaultSpeed,
+	}
+}
+
+func BenchmarkLolcat(b *testing.B) {
+	for _, profile := range []termenv.Profile{termenv.TrueColor, termenv.ANSI256} {
+		b.Run(profileNames[profile], func(b *testing.B) {
+			color := themes[0].color(0)
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				// A new hue every frame, like the animation.
+				color = color.AdjustHue(15)
+				themes[0].lolcat(graphic, color, profile)
+			}
+		})
+	}
+}
+
+func BenchmarkView(b *testing.B) {
+	for _, profile := range []termenv.Profile{termenv.TrueColor, termenv.ANSI256} {
+		b.Run(profileNames[profile], func(b *testing.B) {
+			m := benchModel(profile)
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				m.tick++
+				m.color = m.color.AdjustHue(15)
+				m.View()
+			}
+		})
+	}
+}
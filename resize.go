@@ -0,0 +1,37 @@
+package main
+
+import (
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// resizeEvery is how often a session lays itself out again at most while its
+// window is being dragged to a new size.
+const resizeEvery = 250 * time.Millisecond
+
+// resizedMsg applies the last size of a burst of resizes.
+type resizedMsg struct{}
+
+// resize applies the size of msg right away, unless the window was resized
+// within resizeEvery. Then only the last size of the burst is applied, once
+// resizeEvery has passed.
+func (m model) resize(msg tea.WindowSizeMsg) (model, tea.Cmd) {
+	m.stats.resizes.Add(1)
+	if wait := resizeEvery - time.Since(m.resizedAt); wait > 0 {
+		scheduled := m.pendingSize != nil
+		m.pendingSize = &msg
+		if scheduled {
+			return m, nil
+		}
+		return m, tea.Tick(wait, func(time.Time) tea.Msg { return resizedMsg{} })
+	}
+	return m.applySize(msg)
+}
+
+func (m model) applySize(msg tea.WindowSizeMsg) (model, tea.Cmd) {
+	m.pendingSize = nil
+	m.resizedAt = time.Now()
+	m.width, m.height = m.guard.clamp(msg.Width, msg.Height)
+	return m.updatePages(msg)
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"github.com/charmbracelet/ssh"
+	"github.com/charmbracelet/wish"
+)
+
+// router dispatches the requests of a session to the handler of their type:
+// an interactive shell, an exec request like "ssh host cmd", or a subsystem
+// like sftp. Every handler has its own middleware stack, and the middlewares
+// given to newRouter are applied to all of them.
+type router struct {
+	middlewares  []wish.Middleware
+	shellHandler ssh.Handler
+	execHandler  ssh.Handler
+	subsystems   map[string]ssh.Handler
+}
+
+func newRouter(mw ...wish.Middleware) *router {
+	return &router{middlewares: mw, subsystems: make(map[string]ssh.Handler)}
+}
+
+// chain wraps h in mw, the last middleware being the outermost one, like
+// wish.WithMiddleware does.
+func chain(h ssh.Handler, mw []wish.Middleware) ssh.Handler {
+	for _, m := range mw {
+		h = m(h)
+	}
+	return h
+}
+
+// shell serves sessions that didn't ask for a command or subsystem with h.
+func (r *router) shell(h ssh.Handler, mw ...wish.Middleware) {
+	r.shellHandler = chain(h, mw)
+}
+
+// exec serves sessions running a command with h. Without an exec handler
+// they are served like shells.
+func (r *router) exec(h ssh.Handler, mw ...wish.Middleware) {
+	r.execHandler = chain(h, mw)
+}
+
+// subsystem serves the subsystem name, e.g. "ssh -s stats host", with h.
+func (r *router) subsystem(name string, h ssh.SubsystemHandler, mw ...wish.Middleware) {
+	r.subsystems[name] = chain(ssh.Handler(h), mw)
+}
+
+// serveSession picks the handler of sessions that didn't request a
+// subsystem. Subsystem sessions bypass the server handler, charm's ssh calls
+// their handlers directly.
+func (r *router) serveSession(sess ssh.Session) {
+	if len(sess.Command()) > 0 && r.execHandler != nil {
+		r.execHandler(sess)
+		return
+	}
+	if r.shellHandler != nil {
+		r.shellHandler(sess)
+	}
+}
+
+func (r *router) option() ssh.Option {
+	return func(s *ssh.Server) error {
+		s.Handler = chain(r.serveSession, r.middlewares)
+		s.SubsystemHandlers = make(map[string]ssh.SubsystemHandler, len(r.subsystems))
+		for name, h := range r.subsystems {
+			s.SubsystemHandlers[name] = ssh.SubsystemHandler(chain(h, r.middlewares))
+		}
+		return nil
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+// logSamplingConfig configures per-source log sampling.
+type logSamplingConfig struct {
+	// Interval is the length of a sampling window. Sampling is disabled when
+	// 0, as the windows would never end.
+	Interval duration `json:"interval"`
+	// Burst is how many connections of a source are logged per window, the
+	// rest are summarized in a single line at the end of the window. Sampling
+	// is disabled when 0.
+	Burst int `json:"burst"`
+}
+
+type sampledSource struct {
+	connections int
+	suppressed  int
+}
+
+// logSampler keeps sources hammering the server from flooding the logs.
+type logSampler struct {
+	cfg logSamplingConfig
+
+	mu      sync.Mutex
+	sources map[string]*sampledSource
+}
+
+func newLogSampler(cfg logSamplingConfig) *logSampler {
+	return &logSampler{cfg: cfg, sources: make(map[string]*sampledSource)}
+}
+
+// enabled reports whether connections are sampled at all.
+func (s *logSampler) enabled() bool {
+	return s.cfg.Burst > 0 && s.cfg.Interval > 0
+}
+
+// allow reports whether a new connection from ip should be logged.
+func (s *logSampler) allow(ip net.IP) bool {
+	if !s.enabled() {
+		return true
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	src, ok := s.sources[ip.String()]
+	if !ok {
+		src = &sampledSource{}
+		s.sources[ip.String()] = src
+	}
+	src.connections++
+	if src.connections > s.cfg.Burst {
+		src.suppressed++
+		return false
+	}
+	return true
+}
+
+// run logs a summary for every source that had connections suppressed at the
+// end of each window. It never returns.
+func (s *logSampler) run() {
+	if !s.enabled() {
+		return
+	}
+	interval := time.Duration(s.cfg.Interval)
+	for range time.Tick(interval) {
+		s.mu.Lock()
+		sources := s.sources
+		s.sources = make(map[string]*sampledSource)
+		s.mu.Unlock()
+		for ip, src := range sources {
+			if src.suppressed > 0 {
+				log.Info("Suppressed logs of noisy source",
+					"remote", ip,
+					"connections", src.connections,
+					"suppressed", src.suppressed,
+					"interval", interval,
+				)
+			}
+		}
+	}
+}
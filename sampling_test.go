@@ -0,0 +1,32 @@
+package main
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestLogSamplerBurst(t *testing.T) {
+	s := newLogSampler(logSamplingConfig{Interval: duration(time.Minute), Burst: 2})
+	ip := net.IPv4(192, 0, 2, 1)
+	for i, want := range []bool{true, true, false, false} {
+		if got := s.allow(ip); got != want {
+			t.Errorf("connection %d: allow = %v, want %v", i, got, want)
+		}
+	}
+	if !s.allow(net.IPv4(192, 0, 2, 2)) {
+		t.Error("another source was suppressed")
+	}
+}
+
+func TestLogSamplerWithoutInterval(t *testing.T) {
+	// Without an interval the window never resets, so nothing must be
+	// sampled rather than a source being suppressed forever.
+	s := newLogSampler(logSamplingConfig{Burst: 2})
+	ip := net.IPv4(192, 0, 2, 1)
+	for i := 0; i < 5; i++ {
+		if !s.allow(ip) {
+			t.Fatalf("connection %d suppressed without a sampling interval", i)
+		}
+	}
+}
@@ -0,0 +1,20 @@
+package main
+
+// sandboxConfig configures the optional hardening of the server process. The
+// server is exposed to hostile traffic on purpose, so if something in it is
+// ever exploited, Landlock limits the files the attacker gets to touch and a
+// seccomp allowlist the system calls they get to make.
+type sandboxConfig struct {
+	Enabled bool `json:"enabled"`
+	// ReadOnly, ReadWrite and Exec are the paths the process may read, write
+	// or execute, including everything beneath them. Paths that don't exist
+	// are skipped. The server's own executable is always allowed.
+	ReadOnly  []string `json:"read_only"`
+	ReadWrite []string `json:"read_write"`
+	Exec      []string `json:"exec"`
+}
+
+// sandboxedEnv marks the process the server re-executed itself as once it was
+// restricted, Landlock only restricts the thread that enables it and what it
+// executes.
+const sandboxedEnv = "BOZO_SANDBOXED"
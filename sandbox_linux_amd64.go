@@ -0,0 +1,15 @@
+package main
+
+import "golang.org/x/sys/unix"
+
+const seccompArch = unix.AUDIT_ARCH_X86_64
+
+// seccompArchSyscalls are the legacy system calls amd64 still has and libc
+// uses.
+var seccompArchSyscalls = []uintptr{
+	unix.SYS_ARCH_PRCTL, unix.SYS_OPEN, unix.SYS_STAT, unix.SYS_LSTAT,
+	unix.SYS_NEWFSTATAT, unix.SYS_ACCESS, unix.SYS_READLINK, unix.SYS_POLL,
+	unix.SYS_SELECT, unix.SYS_PIPE, unix.SYS_DUP2, unix.SYS_EPOLL_WAIT,
+	unix.SYS_EPOLL_CREATE, unix.SYS_UNLINK, unix.SYS_RENAME, unix.SYS_MKDIR,
+	unix.SYS_GETPGRP, unix.SYS_TIME,
+}
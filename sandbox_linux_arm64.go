@@ -0,0 +1,11 @@
+package main
+
+import "golang.org/x/sys/unix"
+
+const seccompArch = unix.AUDIT_ARCH_AARCH64
+
+// seccompArchSyscalls are the system calls that are only named differently
+// on arm64.
+var seccompArchSyscalls = []uintptr{
+	unix.SYS_FSTATAT,
+}
@@ -0,0 +1,15 @@
+//go:build !linux || !(amd64 || arm64)
+
+package main
+
+import "errors"
+
+var errSandboxUnsupported = errors.New("sandboxing is only supported on Linux on amd64 and arm64")
+
+func enterLandlock(cfg sandboxConfig) error {
+	return errSandboxUnsupported
+}
+
+func enterSeccomp() error {
+	return errSandboxUnsupported
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/charmbracelet/ssh"
+	"github.com/charmbracelet/wish"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	scannerConnections = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "bozo_scanner_connections_total",
+		Help: "Connections that looked like a scanner, by what gave it away.",
+	}, []string{"kind", "listener"})
+	scannersDropped = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "bozo_scanners_dropped_total",
+		Help: "Connections dropped because the source is a known scanner.",
+	})
+)
+
+// scannerConfig configures dropping sources that keep connecting without ever
+// getting to see the banner.
+type scannerConfig struct {
+	// Threshold is the number of scanner connections within Window after
+	// which further connections of a source are dropped, 0 disables the
+	// detection.
+	Threshold int      `json:"threshold"`
+	Window    duration `json:"window"`
+	// TTL is how long connections of a detected scanner are dropped.
+	TTL duration `json:"ttl"`
+}
+
+const scannerSessionKey contextKey = "scannerSession"
+
+type scanSource struct {
+	since time.Time
+	count int
+	// until is set once the source is detected as a scanner.
+	until time.Time
+}
+
+// scannerDetector counts connections that are closed before the handshake
+// completes or without opening a session. Sources doing that repeatedly are
+// dropped right after accept, before any middleware runs. Sessions without a
+// PTY are fine, admins run commands and visitors pipe the banner that way.
+type scannerDetector struct {
+	cfg scannerConfig
+	bus *eventBus
+
+	mu      sync.Mutex
+	sources map[string]*scanSource
+}
+
+func newScannerDetector(cfg scannerConfig, bus *eventBus) *scannerDetector {
+	return &scannerDetector{cfg: cfg, bus: bus, sources: make(map[string]*scanSource)}
+}
+
+// scannerConn reports to the detector how far the connection got when it is
+// closed.
+type scannerConn struct {
+	net.Conn
+	ctx  ssh.Context
+	d    *scannerDetector
+	once sync.Once
+}
+
+func (c *scannerConn) Close() error {
+	c.once.Do(func() {
+		// The session ID is only set once the handshake completed.
+		if _, ok := c.ctx.Value(ssh.ContextKeySessionID).(string); !ok {
+			c.d.observe(c.ctx, remoteIP(c.RemoteAddr()), "handshake")
+		} else if session, _ := c.ctx.Value(scannerSessionKey).(bool); !session {
+			c.d.observe(c.ctx, remoteIP(c.RemoteAddr()), "no_session")
+		}
+	})
+	return c.Conn.Close()
+}
+
+// connCallback drops connections of detected scanners and watches the others.
+func (d *scannerDetector) connCallback(ctx ssh.Context, conn net.Conn) net.Conn {
+	ip := remoteIP(conn.RemoteAddr())
+	if d.cfg.Threshold <= 0 || ip == nil {
+		return conn
+	}
+	d.mu.Lock()
+	src, ok := d.sources[ip.String()]
+	drop := ok && time.Now().Before(src.until)
+	d.mu.Unlock()
+	if drop {
+		scannersDropped.Inc()
+		return nil
+	}
+	return &scannerConn{Conn: conn, ctx: ctx, d: d}
+}
+
+// observe counts a scanner connection of ip.
+func (d *scannerDetector) observe(ctx ssh.Context, ip net.IP, kind string) {
+	scannerConnections.WithLabelValues(kind, listenerLabel(ctx)).Inc()
+	now := time.Now()
+	d.mu.Lock()
+	src, ok := d.sources[ip.String()]
+	if !ok || now.Sub(src.since) > time.Duration(d.cfg.Window) {
+		src = &scanSource{since: now}
+		d.sources[ip.String()] = src
+	}
+	src.count++
+	detected := src.count == d.cfg.Threshold
+	if detected {
+		src.until = now.Add(time.Duration(d.cfg.TTL))
+	}
+	d.mu.Unlock()
+
+	if detected {
+		log.Debug("Detected scanner", "remote", ip, "kind", kind)
+		d.bus.publish(event{Type: eventAbuse, Time: now, IP: ip, Reason: "scanner"})
+	}
+}
+
+// middleware remembers which connections opened a session.
+func (d *scannerDetector) middleware() wish.Middleware {
+	return func(next ssh.Handler) ssh.Handler {
+		return func(sess ssh.Session) {
+			sess.Context().SetValue(scannerSessionKey, true)
+			next(sess)
+		}
+	}
+}
+
+// cleanup forgets sources whose window and detection have passed. It never
+// returns.
+func (d *scannerDetector) cleanup() {
+	for range time.Tick(time.Minute) {
+		now := time.Now()
+		d.mu.Lock()
+		for ip, src := range d.sources {
+			if now.Sub(src.since) > time.Duration(d.cfg.Window) && now.After(src.until) {
+				delete(d.sources, ip)
+			}
+		}
+		d.mu.Unlock()
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/ssh"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// serveScannerTest serves exec sessions watched by d on a random port.
+func serveScannerTest(t *testing.T, d *scannerDetector) string {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := gossh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := func(sess ssh.Session) {
+		sess.Write([]byte("bozo\n"))
+		sess.Exit(0)
+	}
+	s := &ssh.Server{
+		Handler:      d.middleware()(handler),
+		ConnCallback: d.connCallback,
+	}
+	s.AddHostKey(signer)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Serve(l)
+	t.Cleanup(func() { s.Close() })
+	return l.Addr().String()
+}
+
+func dialScannerTest(t *testing.T, addr string) *gossh.Client {
+	t.Helper()
+	c, err := gossh.Dial("tcp", addr, &gossh.ClientConfig{
+		User:            "bozo",
+		HostKeyCallback: gossh.InsecureIgnoreHostKey(),
+		Timeout:         5 * time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// scanHits waits for the closed connections to be observed and returns how
+// many of them counted as a scanner.
+func scanHits(d *scannerDetector) int {
+	time.Sleep(100 * time.Millisecond)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if src, ok := d.sources["127.0.0.1"]; ok {
+		return src.count
+	}
+	return 0
+}
+
+func TestScannerIgnoresExecSessions(t *testing.T) {
+	d := newScannerDetector(scannerConfig{Threshold: 2, Window: duration(time.Minute), TTL: duration(time.Hour)}, newEventBus())
+	addr := serveScannerTest(t, d)
+	for i := 0; i < 5; i++ {
+		c := dialScannerTest(t, addr)
+		sess, err := c.NewSession()
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := sess.Output("ban list")
+		if err != nil || string(out) != "bozo\n" {
+			t.Fatalf("exec session %d: %q, %v", i, out, err)
+		}
+		c.Close()
+	}
+	if hits := scanHits(d); hits != 0 {
+		t.Errorf("exec sessions counted as %d scanner connections", hits)
+	}
+}
+
+func TestScannerFlagsConnectionsWithoutSession(t *testing.T) {
+	d := newScannerDetector(scannerConfig{Threshold: 2, Window: duration(time.Minute), TTL: duration(time.Hour)}, newEventBus())
+	addr := serveScannerTest(t, d)
+	for i := 0; i < 2; i++ {
+		dialScannerTest(t, addr).Close()
+	}
+	if hits := scanHits(d); hits != 2 {
+		t.Fatalf("connections without a session counted as %d scanner connections, want 2", hits)
+	}
+	if _, err := gossh.Dial("tcp", addr, &gossh.ClientConfig{
+		User:            "bozo",
+		HostKeyCallback: gossh.InsecureIgnoreHostKey(),
+		Timeout:         5 * time.Second,
+	}); err == nil {
+		t.Error("detected scanner was not dropped")
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// screensaverTicks is how many ticks the banner takes to move by one cell.
+const screensaverTicks = 2
+
+// screensaver bounces the banner around the window, like the DVD logo.
+type screensaver struct {
+	x, y   int
+	dx, dy int
+}
+
+// startScreensaver starts the banner in the top left corner, heading down
+// right.
+func (m model) startScreensaver() model {
+	m.screensaver = &screensaver{dx: 1, dy: 1}
+	return m
+}
+
+// stepScreensaver moves the banner, turning it around and shifting its colors
+// where it hits the edge of the window.
+func (m model) stepScreensaver() model {
+	s := m.screensaver
+	if s == nil || m.tick%screensaverTicks != 0 {
+		return m
+	}
+	banner := m.currentBanner()
+	maxX := max(m.width-lipgloss.Width(banner), 0)
+	maxY := max(m.height-lipgloss.Height(banner), 0)
+	var bouncedX, bouncedY bool
+	s.x, s.dx, bouncedX = bounce(s.x, s.dx, maxX)
+	s.y, s.dy, bouncedY = bounce(s.y, s.dy, maxY)
+	if bouncedX || bouncedY {
+		m.color = m.color.AdjustHue(120)
+	}
+	return m
+}
+
+// bounce moves pos by d within [0, limit], turning d around at the ends.
+func bounce(pos, d, limit int) (int, int, bool) {
+	if limit <= 0 {
+		return 0, d, false
+	}
+	pos += d
+	if pos <= 0 || pos >= limit {
+		return min(max(pos, 0), limit), -d, true
+	}
+	return pos, d, false
+}
+
+// screensaverView fills the whole window, so place leaves it where it is.
+func (m model) screensaverView() string {
+	s := m.screensaver
+	left := strings.Repeat(" ", s.x)
+	lines := strings.Split(m.lolcat(m.currentBanner()), "\n")
+	for i, l := range lines {
+		lines[i] = left + l
+	}
+	view := strings.Repeat("\n", s.y) + strings.Join(lines, "\n")
+	return lipgloss.Place(m.width, m.height, lipgloss.Left, lipgloss.Top, view)
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// maxSessionLog is how many lines the session log keeps.
+const maxSessionLog = 200
+
+// sessionLog is what the terminal of the session sent and what it was sent,
+// for the events page. The model keeps a pointer, so all copies of it log to
+// the same place.
+type sessionLog struct {
+	start time.Time
+	lines []string
+}
+
+func newSessionLog() *sessionLog {
+	return &sessionLog{start: time.Now()}
+}
+
+// record logs msg if it is worth showing. Ticks come 24 times a second, only
+// one a second is logged.
+func (l *sessionLog) record(msg tea.Msg) {
+	var line string
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		line = fmt.Sprintf("key %q", msg.String())
+	case tea.MouseMsg:
+		line = fmt.Sprintf("mouse %s at %d,%d", msg.String(), msg.X, msg.Y)
+	case tea.WindowSizeMsg:
+		line = fmt.Sprintf("resize to %dx%d", msg.Width, msg.Height)
+	case tickMsg:
+		if msg.tick%24 != 0 {
+			return
+		}
+		line = fmt.Sprintf("tick %d, %v late", msg.tick, time.Since(msg.sent).Round(time.Microsecond))
+	case lookupMsg:
+		line = "lookups done"
+	case onlineMsg:
+		line = fmt.Sprintf("%d bozos online", msg.total)
+	default:
+		return
+	}
+	l.lines = append(l.lines, fmt.Sprintf("%8.1fs  %s", time.Since(l.start).Seconds(), line))
+	if len(l.lines) > maxSessionLog {
+		l.lines = l.lines[len(l.lines)-maxSessionLog:]
+	}
+}
+
+func (l *sessionLog) String() string {
+	return strings.Join(l.lines, "\n")
+}
+
+// eventsHeight is the number of lines of the events page around the
+// viewport.
+const eventsHeight = 4
+
+// eventsPage tails the session log. It follows new lines unless scrolled up.
+type eventsPage struct {
+	viewport viewport.Model
+	follow   bool
+}
+
+func (*eventsPage) title() string { return "Events" }
+func (*eventsPage) name() string  { return "events" }
+
+func (p *eventsPage) open(m model) (page, tea.Cmd) {
+	vp := viewport.New(m.width, max(m.height-eventsHeight, 1))
+	vp.SetContent(m.sessionLog.String())
+	vp.GotoBottom()
+	return &eventsPage{viewport: vp, follow: true}, nil
+}
+
+func (p *eventsPage) update(m model, msg tea.Msg) (page, tea.Cmd) {
+	vp := p.viewport
+	switch msg.(type) {
+	case tea.WindowSizeMsg:
+		vp.Width = m.width
+		vp.Height = max(m.height-eventsHeight, 1)
+	case tea.KeyMsg, tea.MouseMsg:
+	default:
+		return p, nil
+	}
+	vp.SetContent(m.sessionLog.String())
+	if p.follow {
+		vp.GotoBottom()
+	}
+	var cmd tea.Cmd
+	vp, cmd = vp.Update(msg)
+	return &eventsPage{viewport: vp, follow: vp.AtBottom()}, cmd
+}
+
+func (p *eventsPage) view(m model) string {
+	vp := p.viewport
+	vp.SetContent(m.sessionLog.String())
+	if p.follow {
+		vp.GotoBottom()
+	}
+	return m.lolcat("What your terminal told us\n") + "\n" + m.txtStyle.Render(vp.View()) + "\n"
+}
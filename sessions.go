@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// tickInterval is how often the sessions are animated, 24 times a second.
+const tickInterval = 1000 / 24 * time.Millisecond
+
+// sessionRegistry tracks the Bubble Tea programs of all active sessions, so
+// messages can be sent to all of them, along with the country of each
+// session.
+type sessionRegistry struct {
+	mu       sync.Mutex
+	programs map[*tea.Program]*registeredSession
+}
+
+// registeredSession is a session in the registry. ticks holds the tick the
+// program hasn't picked up yet, if any.
+type registeredSession struct {
+	country string
+	ticks   chan time.Time
+}
+
+func newSessionRegistry() *sessionRegistry {
+	return &sessionRegistry{programs: make(map[*tea.Program]*registeredSession)}
+}
+
+// runTicker ticks all sessions from a single ticker, slower while load says
+// the server is busy. A session still busy with the last tick skips this one
+// rather than holding up the others.
+func (r *sessionRegistry) runTicker(load *loadMonitor) {
+	interval := tickInterval
+	tickIntervalSeconds.Set(interval.Seconds())
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for sent := range t.C {
+		r.mu.Lock()
+		sessions := len(r.programs)
+		for _, s := range r.programs {
+			select {
+			case s.ticks <- sent:
+			default:
+			}
+		}
+		r.mu.Unlock()
+		if next := load.interval(sessions); next != interval {
+			interval = next
+			t.Reset(interval)
+			tickIntervalSeconds.Set(interval.Seconds())
+		}
+	}
+}
+
+// forwardTicks sends the ticks of a session to its program, numbered from
+// the first one it got. It removes the session once its context is done, so
+// nothing of a closed session is left running.
+func (r *sessionRegistry) forwardTicks(ctx context.Context, p *tea.Program, ticks <-chan time.Time) {
+	defer r.remove(p)
+	var tick uint
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case sent := <-ticks:
+			tick++
+			p.Send(tickMsg{tick: tick, sent: sent})
+		}
+	}
+}
+
+// add registers the program of a session from country, which is empty if it
+// is unknown, until ctx is done, and tells everyone who is online now and the
+// others who just connected.
+func (r *sessionRegistry) add(ctx context.Context, p *tea.Program, country string) {
+	ticks := make(chan time.Time, 1)
+	r.mu.Lock()
+	r.programs[p] = &registeredSession{country: country, ticks: ticks}
+	r.mu.Unlock()
+	go r.forwardTicks(ctx, p, ticks)
+	r.broadcast(r.online())
+	r.broadcastExcept(connectedMsg{country}, p)
+}
+
+func (r *sessionRegistry) remove(p *tea.Program) {
+	r.mu.Lock()
+	delete(r.programs, p)
+	r.mu.Unlock()
+	r.broadcast(r.online())
+}
+
+// onlineMsg tells the sessions how many sessions there are, in total and per
+// country.
+type onlineMsg struct {
+	total     int
+	countries map[string]int
+}
+
+func (r *sessionRegistry) online() onlineMsg {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	msg := onlineMsg{total: len(r.programs), countries: make(map[string]int)}
+	for _, s := range r.programs {
+		msg.countries[s.country]++
+	}
+	return msg
+}
+
+// broadcast sends msg to every program. Send blocks until the program
+// receives the message, so every program gets its own goroutine.
+func (r *sessionRegistry) broadcast(msg tea.Msg) {
+	r.broadcastExcept(msg, nil)
+}
+
+// broadcastExcept sends msg to every program but except.
+func (r *sessionRegistry) broadcastExcept(msg tea.Msg, except *tea.Program) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for p := range r.programs {
+		if p != except {
+			go p.Send(msg)
+		}
+	}
+}
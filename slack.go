@@ -0,0 +1,37 @@
+package main
+
+import "strings"
+
+// slackConfig posts the events of the chosen types to a Slack channel.
+type slackConfig struct {
+	// WebhookURL is the URL of the channel's incoming webhook, disabled when
+	// empty.
+	WebhookURL string `json:"webhook_url"`
+	// Events are the event types to post, see notifyEvents.
+	Events []string `json:"events"`
+	// Templates override the messages of event types, as text/template
+	// executed with the notification.
+	Templates map[string]string `json:"templates"`
+	Batch     notifyConfig      `json:"batch"`
+}
+
+// slackEscape escapes what Slack would read as a link or a mention, visitors
+// pick their user name and client version.
+var slackEscape = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// subscribeSlackSink posts a message with a line for every event of the
+// types cfg selects.
+func subscribeSlackSink(bus *eventBus, db *store, cfg slackConfig) error {
+	templates, err := parseTemplates("slack", cfg.Templates)
+	if err != nil {
+		return err
+	}
+	subscribeNotifier(bus, db, "slack", cfg.Batch, eventFilter("slack", cfg.Events), func(batch []notification, dropped int) error {
+		text, err := templates.render(batch, dropped)
+		if err != nil {
+			return err
+		}
+		return postJSON(cfg.WebhookURL, map[string]any{"text": slackEscape.Replace(text)})
+	})
+	return nil
+}
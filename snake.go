@@ -0,0 +1,227 @@
+package main
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/log"
+)
+
+// The snake moves one cell every snakeTicks ticks, the board is at most
+// snakeWidth by snakeHeight cells.
+const (
+	snakeTicks  = 3
+	snakeWidth  = 40
+	snakeHeight = 16
+)
+
+type point struct{ x, y int }
+
+// snake is the mini-game hidden behind the g key.
+type snake struct {
+	width, height int
+	// body is the snake, head first.
+	body []point
+	// dir is where the snake moves, next where it moves after the next step.
+	dir, next point
+	food      point
+	score     int
+	over      bool
+	// scores are the high scores, loaded once the game is over. err is set if
+	// the score couldn't be recorded.
+	scores []leaderboardEntry
+	err    error
+}
+
+// newSnake starts a game on a board fitting into a width by height window.
+func newSnake(width, height int) *snake {
+	g := &snake{
+		width:  max(min(width-2, snakeWidth), 8),
+		height: max(min(height-6, snakeHeight), 4),
+		dir:    point{1, 0},
+		next:   point{1, 0},
+	}
+	head := point{g.width / 2, g.height / 2}
+	g.body = []point{head, {head.x - 1, head.y}, {head.x - 2, head.y}}
+	g.placeFood()
+	return g
+}
+
+// turn changes the direction of the snake, it can't turn back on itself.
+func (g *snake) turn(d point) {
+	if d.x != -g.dir.x || d.y != -g.dir.y {
+		g.next = d
+	}
+}
+
+// step moves the snake by one cell, it reports whether the game just ended.
+func (g *snake) step() bool {
+	if g.over {
+		return false
+	}
+	g.dir = g.next
+	head := point{g.body[0].x + g.dir.x, g.body[0].y + g.dir.y}
+	if head.x < 0 || head.y < 0 || head.x >= g.width || head.y >= g.height || slices.Contains(g.body[:len(g.body)-1], head) {
+		g.over = true
+		return true
+	}
+	g.body = append([]point{head}, g.body...)
+	if head != g.food {
+		g.body = g.body[:len(g.body)-1]
+		return false
+	}
+	g.score++
+	if !g.placeFood() {
+		g.over = true
+		return true
+	}
+	return false
+}
+
+// placeFood puts the food on a random free cell, it reports false if the
+// snake fills the whole board.
+func (g *snake) placeFood() bool {
+	var free []point
+	for y := range g.height {
+		for x := range g.width {
+			if p := (point{x, y}); !slices.Contains(g.body, p) {
+				free = append(free, p)
+			}
+		}
+	}
+	if len(free) == 0 {
+		return false
+	}
+	g.food = free[rand.IntN(len(free))]
+	return true
+}
+
+// board draws the board with its border.
+func (g *snake) board() string {
+	b := strings.Builder{}
+	border := "+" + strings.Repeat("-", g.width) + "+\n"
+	b.WriteString(border)
+	for y := range g.height {
+		b.WriteByte('|')
+		for x := range g.width {
+			switch p := (point{x, y}); {
+			case p == g.body[0]:
+				b.WriteByte('@')
+			case slices.Contains(g.body, p):
+				b.WriteByte('o')
+			case p == g.food:
+				b.WriteByte('*')
+			default:
+				b.WriteByte(' ')
+			}
+		}
+		b.WriteString("|\n")
+	}
+	b.WriteString(border)
+	return b.String()
+}
+
+var snakeDirections = map[string]point{
+	"up": {0, -1}, "w": {0, -1}, "k": {0, -1},
+	"down": {0, 1}, "s": {0, 1}, "j": {0, 1},
+	"left": {-1, 0}, "a": {-1, 0}, "h": {-1, 0},
+	"right": {1, 0}, "d": {1, 0}, "l": {1, 0},
+}
+
+// updateSnake handles the keys while the game is shown.
+func (m model) updateSnake(msg tea.KeyMsg) (model, tea.Cmd) {
+	switch key := msg.String(); {
+	case key == "ctrl+c", m.keys.matches(msg, "quit"):
+		return m.quit(msg)
+	case key == "esc", m.keys.matches(msg, "snake"):
+		m.snake = nil
+	case key == "enter":
+		if m.snake.over {
+			m.snake = newSnake(m.width, m.height)
+		}
+	default:
+		if d, ok := snakeDirections[key]; ok {
+			m.snake.turn(d)
+		}
+	}
+	return m, nil
+}
+
+// stepSnake moves the snake on every snakeTicks tick and records the score
+// once the game is over.
+func (m model) stepSnake() tea.Cmd {
+	if m.snake == nil || m.tick%snakeTicks != 0 || !m.snake.step() {
+		return nil
+	}
+	return m.guard.cmd(recordSnakeScore(m.db, remoteIP(m.address).String(), m.snake.score), snakeScoresMsg{err: errTooBusy})
+}
+
+func (m model) snakeView() string {
+	g := m.snake
+	msg := fmt.Sprintf("Score: %d", g.score)
+	help := "arrows, wasd or hjkl to move, esc to leave"
+	if g.over {
+		msg = fmt.Sprintf("Game over, bozo. Score: %d", g.score)
+		switch {
+		case g.err == errTooBusy:
+			msg += "\n\nToo busy to record your score right now, bozo."
+		case g.scores != nil:
+			msg += "\n\n" + formatLeaderboard("High scores", maskIPs(g.scores))
+		}
+		help = "enter to play again, esc to leave"
+	}
+	return m.lolcat(g.board()) + m.txtStyle.Render(msg) + "\n" + m.quitStyle.Render(help) + "\n"
+}
+
+type snakeScoresMsg struct {
+	scores []leaderboardEntry
+	err    error
+}
+
+// recordSnakeScore keeps the score if it is the best of ip and loads the high
+// scores in the background.
+func recordSnakeScore(db *store, ip string, score int) tea.Cmd {
+	return func() tea.Msg {
+		if err := db.recordSnakeScore(ip, score, time.Now()); err != nil {
+			log.Error("Could not record snake score", "error", err)
+			return nil
+		}
+		scores, err := db.topSnakeScores(leaderboardSize)
+		if err != nil {
+			log.Error("Could not load snake scores", "error", err)
+			return nil
+		}
+		return snakeScoresMsg{scores: scores}
+	}
+}
+
+func (s *store) recordSnakeScore(ip string, score int, now time.Time) error {
+	_, err := s.db.Exec(
+		`INSERT INTO snake_scores (ip, score, time) VALUES (?, ?, ?)
+		ON CONFLICT (ip) DO UPDATE SET score = excluded.score, time = excluded.time WHERE excluded.score > snake_scores.score`,
+		ip, score, now.Unix(),
+	)
+	return err
+}
+
+// topSnakeScores returns the limit best scores, one per IP.
+func (s *store) topSnakeScores(limit int) ([]leaderboardEntry, error) {
+	rows, err := s.db.Query(`SELECT ip, score FROM snake_scores ORDER BY score DESC, time LIMIT ?`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	entries := []leaderboardEntry{}
+	for rows.Next() {
+		var e leaderboardEntry
+		if err := rows.Scan(&e.Name, &e.Visits); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
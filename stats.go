@@ -0,0 +1,202 @@
+package main
+
+import (
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/charmbracelet/ssh"
+	"github.com/charmbracelet/wish"
+)
+
+// sessionStats counts what happened during a single ssh session. The counters
+// are touched from the ssh handler as well as from the Bubble Tea program, so
+// everything is atomic.
+type sessionStats struct {
+	start   time.Time
+	bytes   atomic.Uint64
+	frames  atomic.Uint64
+	resizes atomic.Uint64
+	keys    atomic.Uint64
+	outcome atomic.Pointer[string]
+}
+
+// setOutcome records how the session ended, e.g. "rate_limited".
+func (st *sessionStats) setOutcome(outcome string) {
+	st.outcome.Store(&outcome)
+}
+
+// getOutcome returns how the session ended. When no handler recorded an
+// outcome, sessions that got to see the banner were "served", the others were
+// "rejected".
+func (st *sessionStats) getOutcome() string {
+	if o := st.outcome.Load(); o != nil {
+		return *o
+	}
+	if st.frames.Load() > 0 {
+		return "served"
+	}
+	return "rejected"
+}
+
+// serverStats is the stats subsystem shared by all sessions. It counts
+// visitors, tracks the active sessions and sums up the stats of all sessions
+// that have ended since the server was started.
+type serverStats struct {
+	started  time.Time
+	visitors *visitorCounter
+	active   atomic.Int64
+
+	sessions atomic.Uint64
+	duration atomic.Int64
+	bytes    atomic.Uint64
+	frames   atomic.Uint64
+	resizes  atomic.Uint64
+	keys     atomic.Uint64
+
+	mu    sync.Mutex
+	day   string
+	today uint64
+}
+
+func newServerStats(visitors *visitorCounter) *serverStats {
+	return &serverStats{started: time.Now(), visitors: visitors}
+}
+
+// visit counts a new visitor, both in the persistent visitor counter and in
+// the counter of today's visitors.
+func (t *serverStats) visit(ip net.IP) {
+	t.mu.Lock()
+	if day := time.Now().Format(time.DateOnly); day != t.day {
+		t.day = day
+		t.today = 0
+	}
+	t.today++
+	t.mu.Unlock()
+	t.visitors.visit(ip)
+}
+
+// visitorsToday returns the number of visitors since midnight.
+func (t *serverStats) visitorsToday() uint64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.day != time.Now().Format(time.DateOnly) {
+		return 0
+	}
+	return t.today
+}
+
+func (t *serverStats) uptime() time.Duration {
+	return time.Since(t.started)
+}
+
+// contextKey is the type of the keys this package stores in session contexts.
+type contextKey string
+
+const sessionStatsKey contextKey = "sessionStats"
+
+func (t *serverStats) add(st *sessionStats, d time.Duration) {
+	t.sessions.Add(1)
+	t.duration.Add(int64(d))
+	t.bytes.Add(st.bytes.Load())
+	t.frames.Add(st.frames.Load())
+	t.resizes.Add(st.resizes.Load())
+	t.keys.Add(st.keys.Load())
+}
+
+// statsSession counts every byte written to the underlying ssh.Session and
+// measures how long the writes take.
+type statsSession struct {
+	ssh.Session
+	stats *sessionStats
+}
+
+func (s statsSession) Write(p []byte) (int, error) {
+	start := time.Now()
+	n, err := s.Session.Write(p)
+	sessionWriteSeconds.Observe(time.Since(start).Seconds())
+	s.stats.bytes.Add(uint64(n))
+	return n, err
+}
+
+// getSessionStats returns the stats of the given session, or nil if the
+// session is not tracked by the stats middleware.
+func getSessionStats(s ssh.Session) *sessionStats {
+	st, _ := s.Context().Value(sessionStatsKey).(*sessionStats)
+	return st
+}
+
+// remoteIP returns the IP address of a remote ssh peer.
+func remoteIP(addr net.Addr) net.IP {
+	if tcp, ok := addr.(*net.TCPAddr); ok {
+		return tcp.IP
+	}
+	return nil
+}
+
+// statsMiddleware tracks per-session stats, adds them to the server totals
+// and publishes connect and disconnect events.
+func statsMiddleware(bus *eventBus, totals *serverStats, geo *geoIP) wish.Middleware {
+	return func(next ssh.Handler) ssh.Handler {
+		return func(sess ssh.Session) {
+			e := sessionEvent(sess)
+			e.Type = eventConnect
+			e.Country = geo.country(e.IP)
+			sess.Context().SetValue(sessionCountryKey, e.Country)
+			e.ASN, e.ASNOrg = geo.asn(e.IP)
+			bus.publish(e)
+
+			st := &sessionStats{start: time.Now()}
+			sess.Context().SetValue(sessionStatsKey, st)
+			totals.active.Add(1)
+			next(statsSession{Session: sess, stats: st})
+			totals.active.Add(-1)
+
+			d := time.Since(st.start)
+			totals.add(st, d)
+			e.Type = eventDisconnect
+			e.Time = time.Now()
+			e.Stats = st
+			e.Duration = d
+			bus.publish(e)
+		}
+	}
+}
+
+// sessionEvent returns an event with the fields describing sess filled in.
+func sessionEvent(sess ssh.Session) event {
+	pty, _, _ := sess.Pty()
+	return event{
+		Time:          time.Now(),
+		SessionID:     sess.Context().SessionID(),
+		IP:            remoteIP(sess.RemoteAddr()),
+		User:          sess.User(),
+		ClientVersion: sess.Context().ClientVersion(),
+		Term:          pty.Term,
+		Listener:      listenerLabel(sess.Context()),
+		Env:           capturedEnv(sess.Context()),
+		DNSBL:         listedZones(sess, 0),
+	}
+}
+
+// sessionScore guesses how likely it is that a human was behind a session, from
+// 0 (certainly a bot) to 100. Bots rarely press keys, resize their window or
+// stick around to look at the banner.
+func sessionScore(st *sessionStats, d time.Duration) int {
+	score := 0
+	if st.keys.Load() > 0 {
+		score += 40
+	}
+	// The first resize is sent by the server when the program starts.
+	if st.resizes.Load() > 1 {
+		score += 20
+	}
+	if d > 5*time.Second {
+		score += 20
+	}
+	if st.frames.Load() > 0 {
+		score += 20
+	}
+	return score
+}
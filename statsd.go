@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/charmbracelet/log"
+)
+
+// statsdConfig configures pushing metrics to a StatsD server, as an
+// alternative to Prometheus scraping.
+type statsdConfig struct {
+	// Address of the StatsD server, e.g. "127.0.0.1:8125". Disabled when empty.
+	Address string `json:"address"`
+	// Prefix is prepended to all metric names.
+	Prefix string `json:"prefix"`
+	// Tags adds DogStatsD tags (country, client version, outcome). Disable
+	// for StatsD servers that don't understand them.
+	Tags bool `json:"tags"`
+}
+
+type statsdClient struct {
+	cfg  statsdConfig
+	conn net.Conn
+}
+
+func newStatsdClient(cfg statsdConfig) (*statsdClient, error) {
+	conn, err := net.Dial("udp", cfg.Address)
+	if err != nil {
+		return nil, err
+	}
+	return &statsdClient{cfg: cfg, conn: conn}, nil
+}
+
+// statsdMetric formats a single metric line, tags are key, value pairs.
+func (c *statsdClient) metric(name string, value any, kind string, tags ...string) string {
+	line := fmt.Sprintf("%s%s:%v|%s", c.cfg.Prefix, name, value, kind)
+	if c.cfg.Tags && len(tags) > 0 {
+		pairs := make([]string, 0, len(tags)/2)
+		for i := 0; i+1 < len(tags); i += 2 {
+			if tags[i+1] == "" {
+				continue
+			}
+			pairs = append(pairs, tags[i]+":"+statsdTagValue(tags[i+1]))
+		}
+		if len(pairs) > 0 {
+			line += "|#" + strings.Join(pairs, ",")
+		}
+	}
+	return line
+}
+
+// send writes all lines in a single datagram.
+func (c *statsdClient) send(lines ...string) {
+	if _, err := c.conn.Write([]byte(strings.Join(lines, "\n"))); err != nil {
+		log.Debug("Could not send statsd metrics", "error", err)
+	}
+}
+
+// statsdTagValue replaces the characters that have a meaning in the DogStatsD
+// protocol, client versions are sent by the client and can contain anything.
+func statsdTagValue(v string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r == '|' || r == ',' || r == '#' || r == ':' || r == ' ' || r < 0x20 || r == 0x7f:
+			return '_'
+		}
+		return r
+	}, strings.ToLower(v))
+}
+
+// subscribeStatsdSink pushes connects, session duration and traffic, auths and
+// bans to StatsD.
+func subscribeStatsdSink(bus *eventBus, c *statsdClient) {
+	bus.subscribe("statsd", 1024, func(e event) {
+		tags := []string{"country", e.Country, "client", e.ClientVersion}
+		switch e.Type {
+		case eventConnect:
+			c.send(c.metric("connect", 1, "c", tags...))
+		case eventDisconnect:
+			tags = append(tags, "outcome", e.Stats.getOutcome())
+			c.send(
+				c.metric("session.duration", e.Duration.Milliseconds(), "ms", tags...),
+				c.metric("session.bytes", e.Stats.bytes.Load(), "c", tags...),
+				c.metric("session.frames", e.Stats.frames.Load(), "c", tags...),
+				c.metric("session.keys", e.Stats.keys.Load(), "c", tags...),
+			)
+		case eventAuth:
+			outcome := "failure"
+			if e.Success {
+				outcome = "success"
+			}
+			c.send(c.metric("auth", 1, "c", append(tags, "method", e.Method, "outcome", outcome)...))
+		case eventBan:
+			c.send(c.metric("ban", 1, "c", append(tags, "reason", e.Reason)...))
+		}
+	})
+}
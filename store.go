@@ -0,0 +1,160 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/charmbracelet/log"
+	_ "modernc.org/sqlite"
+)
+
+// migrations bring the database schema up to date. The schema version is kept
+// in PRAGMA user_version, migrations[i] upgrades version i to i+1. Never edit
+// a migration that was released, append a new one instead.
+var migrations = []string{
+	`CREATE TABLE visits (
+		id INTEGER PRIMARY KEY,
+		time INTEGER NOT NULL,
+		ip TEXT NOT NULL,
+		country TEXT NOT NULL,
+		client_version TEXT NOT NULL,
+		duration_ms INTEGER NOT NULL,
+		score INTEGER NOT NULL
+	);
+	CREATE INDEX visits_time ON visits (time);
+	CREATE INDEX visits_ip ON visits (ip);`,
+	`ALTER TABLE visits ADD COLUMN asn INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE visits ADD COLUMN asn_org TEXT NOT NULL DEFAULT '';`,
+	`CREATE TABLE bans (
+		cidr TEXT PRIMARY KEY,
+		reason TEXT NOT NULL,
+		created INTEGER NOT NULL,
+		expires INTEGER
+	);`,
+	`CREATE TABLE regulars (
+		fingerprint TEXT PRIMARY KEY,
+		nickname TEXT NOT NULL,
+		first_seen INTEGER NOT NULL,
+		last_seen INTEGER NOT NULL,
+		visits INTEGER NOT NULL
+	);`,
+	`CREATE TABLE preferences (
+		fingerprint TEXT PRIMARY KEY,
+		theme TEXT NOT NULL,
+		speed TEXT NOT NULL,
+		reduced_motion INTEGER NOT NULL
+	);`,
+	`CREATE TABLE snake_scores (
+		ip TEXT PRIMARY KEY,
+		score INTEGER NOT NULL,
+		time INTEGER NOT NULL
+	);`,
+	`CREATE TABLE guestbook (
+		id INTEGER PRIMARY KEY,
+		time INTEGER NOT NULL,
+		ip TEXT NOT NULL,
+		name TEXT NOT NULL,
+		message TEXT NOT NULL,
+		flagged INTEGER NOT NULL,
+		hidden INTEGER NOT NULL
+	);
+	CREATE INDEX guestbook_ip_time ON guestbook (ip, time);`,
+	`CREATE INDEX visits_country ON visits (country);`,
+}
+
+// store persists everything the server wants to remember in SQLite.
+type store struct {
+	db *sql.DB
+}
+
+// openStore opens the database at path, creating it if needed, and migrates
+// it to the latest schema.
+func openStore(path string) (*store, error) {
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)")
+	if err != nil {
+		return nil, err
+	}
+	// SQLite doesn't do concurrent writes, serialize them here instead of
+	// running into SQLITE_BUSY.
+	db.SetMaxOpenConns(1)
+	s := &store{db: db}
+	if err := s.migrate(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *store) close() error {
+	return s.db.Close()
+}
+
+func (s *store) migrate() error {
+	var version int
+	if err := s.db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return err
+	}
+	for ; version < len(migrations); version++ {
+		tx, err := s.db.Begin()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(migrations[version]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %d: %w", version+1, err)
+		}
+		// PRAGMA doesn't take parameters.
+		if _, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", version+1)); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+		log.Info("Migrated database", "version", version+1)
+	}
+	return nil
+}
+
+// visit is a single recorded session.
+type visit struct {
+	Time          time.Time
+	IP            string
+	Country       string
+	ASN           uint
+	ASNOrg        string
+	ClientVersion string
+	Duration      time.Duration
+	Score         int
+}
+
+func (s *store) recordVisit(v visit) error {
+	_, err := s.db.Exec(
+		`INSERT INTO visits (time, ip, country, asn, asn_org, client_version, duration_ms, score) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		v.Time.Unix(), v.IP, v.Country, v.ASN, v.ASNOrg, v.ClientVersion, v.Duration.Milliseconds(), v.Score,
+	)
+	return err
+}
+
+// subscribeStoreSink records every visit on disconnect.
+func subscribeStoreSink(bus *eventBus, st *store) {
+	bus.subscribe("store", 1024, func(e event) {
+		if e.Type != eventDisconnect {
+			return
+		}
+		err := st.recordVisit(visit{
+			Time:          e.Time.Add(-e.Duration),
+			IP:            e.IP.String(),
+			Country:       e.Country,
+			ASN:           e.ASN,
+			ASNOrg:        e.ASNOrg,
+			ClientVersion: e.ClientVersion,
+			Duration:      e.Duration,
+			Score:         sessionScore(e.Stats, e.Duration),
+		})
+		if err != nil {
+			log.Error("Could not record visit", "error", err)
+		}
+	})
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/charmbracelet/ssh"
+)
+
+// statsSubsystem writes the server stats as JSON.
+func statsSubsystem(totals *serverStats) ssh.SubsystemHandler {
+	return func(sess ssh.Session) {
+		total, unique := totals.visitors.counts()
+		stats := struct {
+			VisitorsToday  uint64  `json:"visitors_today"`
+			VisitorsTotal  uint64  `json:"visitors_total"`
+			VisitorsUnique uint64  `json:"visitors_unique"`
+			ActiveSessions int64   `json:"active_sessions"`
+			Sessions       uint64  `json:"sessions"`
+			Bytes          uint64  `json:"bytes"`
+			Frames         uint64  `json:"frames"`
+			UptimeSeconds  float64 `json:"uptime_seconds"`
+		}{
+			VisitorsToday:  totals.visitorsToday(),
+			VisitorsTotal:  total,
+			VisitorsUnique: unique,
+			ActiveSessions: totals.active.Load(),
+			Sessions:       totals.sessions.Load(),
+			Bytes:          totals.bytes.Load(),
+			Frames:         totals.frames.Load(),
+			UptimeSeconds:  totals.uptime().Round(time.Second).Seconds(),
+		}
+		getSessionStats(sess).setOutcome("subsystem")
+		if err := json.NewEncoder(sess).Encode(stats); err != nil {
+			log.Debug("Could not write stats", "error", err)
+		}
+	}
+}
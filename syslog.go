@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// syslogConfig configures sending logs to syslog in addition to stderr.
+type syslogConfig struct {
+	Enabled bool `json:"enabled"`
+	// Network is "udp" or "tcp" for a remote syslog server. When empty, the
+	// local syslog socket is used.
+	Network string `json:"network"`
+	Address string `json:"address"`
+	// Facility is the syslog facility name, e.g. "daemon" or "local0".
+	Facility string `json:"facility"`
+	// Tag is sent as the RFC5424 APP-NAME.
+	Tag string `json:"tag"`
+}
+
+var syslogFacilities = map[string]int{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5,
+	"lpr": 6, "news": 7, "uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19,
+	"local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+// Syslog severities of the charmbracelet/log levels, as they are printed by
+// the text formatter.
+var syslogSeverities = map[string]int{
+	"FATA": 2, // critical
+	"ERRO": 3, // error
+	"WARN": 4, // warning
+	"INFO": 6, // informational
+	"DEBU": 7, // debug
+}
+
+// Log lines without a level, e.g. from log.Print, are sent as notice.
+const syslogDefaultSeverity = 5
+
+// syslogTimeout bounds connecting and writing to syslog, so a stalled server
+// is reconnected to instead of holding up the records behind it.
+const syslogTimeout = 5 * time.Second
+
+var localSyslogSockets = []string{"/dev/log", "/var/run/syslog", "/var/run/log"}
+
+var ansiSequence = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+// syslogWriter is an io.Writer that sends every log record written to it as
+// an RFC5424 message to syslog.
+type syslogWriter struct {
+	mu       sync.Mutex
+	network  string
+	address  string
+	facility int
+	tag      string
+	hostname string
+	conn     net.Conn
+}
+
+func newSyslogWriter(cfg syslogConfig) (*syslogWriter, error) {
+	facility, ok := syslogFacilities[cfg.Facility]
+	if !ok {
+		return nil, fmt.Errorf("unknown syslog facility %q", cfg.Facility)
+	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "-"
+	}
+	w := &syslogWriter{
+		network:  cfg.Network,
+		address:  cfg.Address,
+		facility: facility,
+		tag:      cfg.Tag,
+		hostname: hostname,
+	}
+	if err := w.connect(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *syslogWriter) connect() error {
+	if w.network != "" {
+		conn, err := net.DialTimeout(w.network, w.address, syslogTimeout)
+		if err != nil {
+			return err
+		}
+		w.conn = conn
+		return nil
+	}
+	var err error
+	for _, path := range localSyslogSockets {
+		var conn net.Conn
+		if conn, err = net.DialTimeout("unixgram", path, syslogTimeout); err == nil {
+			w.conn = conn
+			return nil
+		}
+	}
+	return fmt.Errorf("no local syslog socket: %w", err)
+}
+
+// Write sends a single log record. charmbracelet/log writes each record with
+// one call, so p is always exactly one line.
+func (w *syslogWriter) Write(p []byte) (int, error) {
+	severity, msg := parseLogRecord(p)
+	line := fmt.Sprintf("<%d>1 %s %s %s %d - - %s",
+		w.facility*8+severity,
+		time.Now().Format("2006-01-02T15:04:05.000000Z07:00"),
+		w.hostname,
+		w.tag,
+		os.Getpid(),
+		msg,
+	)
+	// TCP needs octet-counting framing (RFC6587), datagrams are self-delimiting.
+	if w.network == "tcp" {
+		line = fmt.Sprintf("%d %s", len(line), line)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.conn == nil {
+		if err := w.connect(); err != nil {
+			return 0, err
+		}
+	}
+	if err := w.send(line); err != nil {
+		// The syslog daemon might have been restarted, retry once.
+		w.conn.Close()
+		w.conn = nil
+		if err := w.connect(); err != nil {
+			return 0, err
+		}
+		if err := w.send(line); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+func (w *syslogWriter) send(line string) error {
+	w.conn.SetWriteDeadline(time.Now().Add(syslogTimeout))
+	_, err := io.WriteString(w.conn, line)
+	return err
+}
+
+// parseLogRecord extracts the syslog severity and the message from a record
+// printed by the charmbracelet/log text formatter. The timestamp of the record
+// is dropped, as syslog carries its own.
+func parseLogRecord(p []byte) (int, string) {
+	line := strings.TrimSpace(ansiSequence.ReplaceAllString(string(bytes.TrimSpace(p)), ""))
+	fields := strings.Fields(line)
+	for i, f := range fields {
+		if sev, ok := syslogSeverities[f]; ok {
+			return sev, strings.Join(fields[i+1:], " ")
+		}
+		// The level follows the timestamp, if it isn't within the first
+		// fields there is none.
+		if i >= 2 {
+			break
+		}
+	}
+	return syslogDefaultSeverity, line
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+// telegramConfig messages the operator about notable visitors through a
+// Telegram bot.
+type telegramConfig struct {
+	// Token is the token of the bot, disabled when empty.
+	Token string `json:"token"`
+	// ChatID is the chat the bot messages, the operator has to start a chat
+	// with the bot first.
+	ChatID string `json:"chat_id"`
+	// APIURL is the Bot API server.
+	APIURL string `json:"api_url"`
+	// HumanScore is the sessionScore from which a session is notable, 0
+	// leaves sessions out.
+	HumanScore int          `json:"human_score"`
+	Batch      notifyConfig `json:"batch"`
+}
+
+// telegramMaxText is the longest message Telegram accepts.
+const telegramMaxText = 4096
+
+// subscribeTelegramSink messages the first visit from a country, sessions
+// that were likely human and guestbook entries.
+func subscribeTelegramSink(bus *eventBus, db *store, cfg telegramConfig) {
+	url := strings.TrimSuffix(cfg.APIURL, "/") + "/bot" + cfg.Token + "/sendMessage"
+	subscribeNotifier(bus, db, "telegram", cfg.Batch, telegramNotable(db, cfg), func(batch []notification, dropped int) error {
+		return postJSON(url, map[string]any{
+			"chat_id":                  cfg.ChatID,
+			"text":                     telegramText(batch, dropped),
+			"parse_mode":               "HTML",
+			"disable_web_page_preview": true,
+		})
+	})
+}
+
+// telegramNotable accepts the events worth a message. Countries are
+// remembered once seen, so bozos from a new country that are online at the
+// same time only count once.
+func telegramNotable(db *store, cfg telegramConfig) func(event) bool {
+	seen := make(map[string]bool)
+	return func(e event) bool {
+		switch e.Type {
+		case eventConnect:
+			if e.Country == "" || seen[e.Country] {
+				return false
+			}
+			seen[e.Country] = true
+			visited, err := db.visitedFrom(e.Country)
+			if err != nil {
+				log.Error("Could not look up country", "error", err)
+				return false
+			}
+			return !visited
+		case eventDisconnect:
+			return cfg.HumanScore > 0 && e.Stats != nil && sessionScore(e.Stats, e.Duration) >= cfg.HumanScore
+		case eventGuestbook:
+			return true
+		}
+		return false
+	}
+}
+
+func telegramText(batch []notification, dropped int) string {
+	var b strings.Builder
+	for _, n := range batch {
+		switch n.Type {
+		case eventConnect:
+			fmt.Fprintf(&b, "First bozo from <b>%s</b>: %s\n", html.EscapeString(n.Country), telegramDetails(n))
+		case eventDisconnect:
+			fmt.Fprintf(&b, "Probably a human, score <b>%d</b>: %s, stayed %v and pressed %d keys\n",
+				n.Score, telegramDetails(n), n.Duration.Round(time.Second), n.Stats.keys.Load())
+		case eventGuestbook:
+			approval := ""
+			if n.Flagged {
+				approval = " (needs approval)"
+			}
+			fmt.Fprintf(&b, "<b>%s</b> signed the guestbook%s: %s\n", html.EscapeString(n.Name), approval, html.EscapeString(n.Message))
+		}
+	}
+	if dropped > 0 {
+		fmt.Fprintf(&b, "…and %d more\n", dropped)
+	}
+	// Cutting the HTML could leave a tag open, which Telegram refuses.
+	text := b.String()
+	if len(text) > telegramMaxText {
+		text = truncate(text, telegramMaxText)
+		if i := strings.LastIndexByte(text, '\n'); i > 0 {
+			text = text[:i+1] + "…"
+		}
+	}
+	return text
+}
+
+// telegramDetails describes the IP, network and client of n.
+func telegramDetails(n notification) string {
+	details := "<code>" + n.IP.String() + "</code>"
+	if n.Country != "" && n.Type != eventConnect {
+		details += " (" + html.EscapeString(n.Country) + ")"
+	}
+	if n.ASNOrg != "" {
+		details += " " + html.EscapeString(n.ASNOrg)
+	}
+	return details + " <code>" + html.EscapeString(n.ClientVersion) + "</code>"
+}
+
+// visitedFrom reports whether a visit from country was recorded.
+func (s *store) visitedFrom(country string) (bool, error) {
+	var visited bool
+	err := s.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM visits WHERE country = ?)`, country).Scan(&visited)
+	return visited, err
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"math"
+	"strconv"
+	"sync"
+
+	"github.com/muesli/termenv"
+	"github.com/teacat/noire"
+)
+
+// theme is a color preset of the rainbow. The hue moves by step every tick,
+// by gradient from one character to the next and by angle from one row to
+// the next.
+type theme struct {
+	name       string
+	saturation float64
+	value      float64
+	step       float64
+	gradient   float64
+	angle      float64
+}
+
+// themes are the presets visitors can cycle through, the first is the
+// default.
+var themes = []theme{
+	{name: "rainbow", saturation: 66, value: 100, step: 15, gradient: 6, angle: 6},
+	{name: "pastel", saturation: 30, value: 100, step: 8, gradient: 4, angle: 4},
+	{name: "neon", saturation: 100, value: 100, step: 25, gradient: 12, angle: 10},
+	{name: "chill", saturation: 50, value: 85, step: 3, gradient: 2, angle: 2},
+	{name: "glitch", saturation: 80, value: 100, step: 90, gradient: 45, angle: 30},
+}
+
+// color returns the color of the theme with the given hue.
+func (t theme) color(hue float64) noire.Color {
+	return noire.NewHSV(hue, t.saturation, t.value)
+}
+
+// lolcat renders msg in a rainbow starting at initialColor. It writes the
+// escape sequences itself rather than through a style for every character,
+// the banner is recolored every frame. Spaces keep the color they come with
+// and every line is reset at its end, so padding stays uncolored.
+func (t theme) lolcat(msg string, initialColor noire.Color, profile termenv.Profile) string {
+	g := t.table(msg, initialColor, profile)
+	builder := getBuffer()
+	defer putBuffer(builder)
+	row, col := 0, 0
+	colored := false
+	for _, c := range msg {
+		switch c {
+		case '\n':
+			if colored {
+				builder.WriteString(sgrReset)
+				colored = false
+			}
+			builder.WriteRune(c)
+			row, col = row+1, 0
+			continue
+		case ' ':
+		default:
+			if seq := g[row][col]; seq != "" {
+				builder.WriteString(seq)
+				colored = true
+			}
+		}
+		builder.WriteRune(c)
+		col++
+	}
+	if colored {
+		builder.WriteString(sgrReset)
+	}
+	return builder.String()
+}
+
+// sgrReset resets all colors and attributes.
+const sgrReset = termenv.CSI + termenv.ResetSeq + "m"
+
+// foreground is the sequence switching the foreground to color in profile,
+// empty if the profile has no colors. True colors are written straight away,
+// the others go through termenv to find the closest color once per color.
+func foreground(color noire.Color, profile termenv.Profile) string {
+	switch profile {
+	case termenv.Ascii:
+		return ""
+	case termenv.TrueColor:
+		r, g, b := color.RGB()
+		s := append(make([]byte, 0, 24), termenv.CSI+"38;2;"...)
+		s = strconv.AppendInt(s, int64(math.Round(r)), 10)
+		s = append(s, ';')
+		s = strconv.AppendInt(s, int64(math.Round(g)), 10)
+		s = append(s, ';')
+		s = strconv.AppendInt(s, int64(math.Round(b)), 10)
+		return string(append(s, 'm'))
+	}
+	key := foregroundKey{rgb(color), profile}
+	foregrounds.Lock()
+	seq, ok := foregrounds.seqs[key]
+	foregrounds.Unlock()
+	if ok {
+		return seq
+	}
+	if seq = profile.Color(hexColor(color)).Sequence(false); seq != "" {
+		seq = termenv.CSI + seq + "m"
+	}
+	foregrounds.Lock()
+	if len(foregrounds.seqs) >= maxForegrounds {
+		clear(foregrounds.seqs)
+	}
+	foregrounds.seqs[key] = seq
+	foregrounds.Unlock()
+	return seq
+}
+
+// maxForegrounds is how many sequences of the closest colors are kept before
+// they are all dropped.
+const maxForegrounds = 8192
+
+type foregroundKey struct {
+	rgb     [3]uint8
+	profile termenv.Profile
+}
+
+// foregrounds are the sequences of the closest colors in the profiles with
+// fewer colors, finding them is slow.
+var foregrounds = struct {
+	sync.Mutex
+	seqs map[foregroundKey]string
+}{seqs: make(map[foregroundKey]string)}
+
+// rgb is color in 8 bits per channel.
+func rgb(color noire.Color) [3]uint8 {
+	r, g, b := color.RGB()
+	return [3]uint8{uint8(math.Round(r)), uint8(math.Round(g)), uint8(math.Round(b))}
+}
+
+// hexColor formats color as #RRGGBB, without fmt and the upper casing noire
+// goes through.
+func hexColor(color noire.Color) string {
+	const digits = "0123456789ABCDEF"
+	buf := [7]byte{'#'}
+	for i, c := range rgb(color) {
+		buf[1+2*i] = digits[c>>4]
+		buf[2+2*i] = digits[c&0xf]
+	}
+	return string(buf[:])
+}
+
+// lolcat renders msg in the theme of the session.
+func (m model) lolcat(msg string) string {
+	if m.plain {
+		return msg
+	}
+	return themes[m.theme].lolcat(msg, m.color, m.profile)
+}
+
+// cycleTheme switches to the next theme, keeping the current hue.
+func (m model) cycleTheme() model {
+	m.theme = (m.theme + 1) % len(themes)
+	m.color = themes[m.theme].color(m.color.Hue())
+	return m
+}
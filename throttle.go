@@ -0,0 +1,54 @@
+package main
+
+import (
+	"github.com/charmbracelet/ssh"
+	"github.com/charmbracelet/wish"
+	"golang.org/x/time/rate"
+)
+
+// throttleConfig limits the output bandwidth of every session.
+type throttleConfig struct {
+	// BytesPerSecond is the sustained output rate, 0 disables throttling.
+	BytesPerSecond int `json:"bytes_per_second"`
+	// Burst is how many bytes can be written at once.
+	Burst int `json:"burst"`
+}
+
+// throttledSession blocks writes once the session used up its bandwidth. Bubble
+// Tea only ever keeps the latest frame around, so a client that can't keep up
+// just gets fewer frames instead of an ever growing buffer.
+type throttledSession struct {
+	ssh.Session
+	limiter *rate.Limiter
+}
+
+func (s throttledSession) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		chunk := min(len(p), s.limiter.Burst())
+		if err := s.limiter.WaitN(s.Context(), chunk); err != nil {
+			return written, err
+		}
+		n, err := s.Session.Write(p[:chunk])
+		written += n
+		if err != nil {
+			return written, err
+		}
+		p = p[chunk:]
+	}
+	return written, nil
+}
+
+// throttleMiddleware limits the output bandwidth of each session.
+func throttleMiddleware(cfg throttleConfig) wish.Middleware {
+	return func(next ssh.Handler) ssh.Handler {
+		return func(sess ssh.Session) {
+			if cfg.BytesPerSecond <= 0 {
+				next(sess)
+				return
+			}
+			burst := max(cfg.Burst, 1)
+			next(throttledSession{Session: sess, limiter: rate.NewLimiter(rate.Limit(cfg.BytesPerSecond), burst)})
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// toastFor is how long a toast is shown.
+const toastFor = 4 * time.Second
+
+// connectedMsg tells the sessions that a visitor from country connected.
+type connectedMsg struct {
+	country string
+}
+
+// showToast shows text below the page for a moment.
+func (m model) showToast(text string) model {
+	m.toast = text
+	m.toastUntil = time.Now().Add(toastFor)
+	return m
+}
+
+// connectedToast announces a new visitor, unless the visitor muted the
+// toasts.
+func (m model) connectedToast(msg connectedMsg) model {
+	if m.muted {
+		return m
+	}
+	if msg.country == "" {
+		return m.showToast(translate(m.lang, "Someone just connected."))
+	}
+	from := countryFlag(msg.country) + " " + msg.country
+	return m.showToast(fmt.Sprintf(translate(m.lang, "Someone from %s just connected."), from))
+}
+
+// toggleMuted mutes or unmutes the toasts about new visitors.
+func (m model) toggleMuted() model {
+	m.muted = !m.muted
+	if m.muted {
+		return m.showToast(fmt.Sprintf(translate(m.lang, "Muted, %s to hear about new bozos again."), m.keys.first("mute")))
+	}
+	return m.showToast(fmt.Sprintf(translate(m.lang, "Unmuted, %s to mute again."), m.keys.first("mute")))
+}
+
+func (m model) toastView() string {
+	if time.Now().After(m.toastUntil) {
+		return ""
+	}
+	return m.txtStyle.Render(m.toast)
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+// visitorSaveInterval is how often the visitor counter is written to disk at
+// most, a flood of connections must not turn into a flood of writes.
+const visitorSaveInterval = 10 * time.Second
+
+// visitorCounter counts total and unique visitors and persists the counts to
+// a file, so they survive restarts. Unique visitors are identified by an HMAC
+// of their IP address keyed with a secret kept in a file of its own, raw
+// addresses are never written to disk.
+type visitorCounter struct {
+	path   string
+	secret []byte
+
+	mu     sync.Mutex
+	total  uint64
+	unique map[string]struct{}
+	dirty  bool
+
+	// saveMu serializes the writes of the file.
+	saveMu sync.Mutex
+}
+
+type visitorFile struct {
+	Total  uint64   `json:"total"`
+	Unique []string `json:"unique"`
+}
+
+// loadVisitorCounter reads the counter persisted at path and the secret next
+// to it, creating the secret if needed. If the file does not exist yet the
+// counter starts at zero.
+func loadVisitorCounter(path string) (*visitorCounter, error) {
+	secret, err := loadVisitorSecret(path + ".key")
+	if err != nil {
+		return nil, err
+	}
+	v := &visitorCounter{path: path, secret: secret, unique: make(map[string]struct{})}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return v, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var f visitorFile
+	if err := json.Unmarshal(b, &f); err != nil {
+		return nil, err
+	}
+	v.total = f.Total
+	for _, h := range f.Unique {
+		v.unique[h] = struct{}{}
+	}
+	return v, nil
+}
+
+// loadVisitorSecret reads the HMAC key at path, generating it on first use.
+func loadVisitorSecret(path string) ([]byte, error) {
+	b, err := os.ReadFile(path)
+	if err == nil {
+		return hex.DecodeString(string(b))
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path, []byte(hex.EncodeToString(secret)), 0o600); err != nil {
+		return nil, err
+	}
+	return secret, nil
+}
+
+// visit counts a visit from ip. The counts are persisted by run.
+func (v *visitorCounter) visit(ip net.IP) {
+	mac := hmac.New(sha256.New, v.secret)
+	mac.Write(ip)
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.total++
+	v.unique[hex.EncodeToString(mac.Sum(nil))] = struct{}{}
+	v.dirty = true
+}
+
+// counts returns the total and unique visitor counts.
+func (v *visitorCounter) counts() (total, unique uint64) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.total, uint64(len(v.unique))
+}
+
+// run saves the counter every visitorSaveInterval if it changed. It never
+// returns.
+func (v *visitorCounter) run() {
+	for range time.Tick(visitorSaveInterval) {
+		if err := v.flush(); err != nil {
+			log.Error("Could not persist visitor counter", "error", err)
+		}
+	}
+}
+
+// flush saves the counter if it changed since the last save.
+func (v *visitorCounter) flush() error {
+	v.saveMu.Lock()
+	defer v.saveMu.Unlock()
+	v.mu.Lock()
+	if !v.dirty {
+		v.mu.Unlock()
+		return nil
+	}
+	f := visitorFile{Total: v.total, Unique: make([]string, 0, len(v.unique))}
+	for h := range v.unique {
+		f.Unique = append(f.Unique, h)
+	}
+	v.dirty = false
+	v.mu.Unlock()
+	if err := v.save(f); err != nil {
+		v.mu.Lock()
+		v.dirty = true
+		v.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// save writes f to a temporary file and renames it into place, so a crash
+// mid-write never leaves a truncated file behind.
+func (v *visitorCounter) save(f visitorFile) error {
+	b, err := json.Marshal(f)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(v.path), ".visitors-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), v.path)
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"text/template"
+)
+
+// webhookConfig posts the events of the chosen types to any URL.
+type webhookConfig struct {
+	// URL is where the events are posted to, disabled when empty.
+	URL string `json:"url"`
+	// Headers are sent along, like an Authorization header.
+	Headers map[string]string `json:"headers"`
+	// Secret signs the body with HMAC-SHA256 in the X-Bozo-Signature header,
+	// as sha256=<hex>. No signature is sent when empty.
+	Secret string `json:"secret"`
+	// Events are the event types to post, see notifyEvents.
+	Events []string `json:"events"`
+	// Body is the text/template of the JSON body, executed with a
+	// webhookBatch. The json function encodes a value as JSON.
+	Body  string       `json:"body"`
+	Batch notifyConfig `json:"batch"`
+}
+
+// webhookBatch is what the body template is executed with.
+type webhookBatch struct {
+	Events  []notification
+	Dropped int
+}
+
+// defaultWebhookBody lists the events with the fields most of them have.
+const defaultWebhookBody = `{"events": [{{range $i, $e := .Events}}{{if $i}}, {{end}}{
+	"type": {{json $e.Type}},
+	"time": {{json $e.Time}},
+	"ip": {{json $e.IP}},
+	"country": {{json $e.Country}},
+	"user": {{json $e.User}},
+	"client_version": {{json $e.ClientVersion}}
+}{{end}}], "dropped": {{.Dropped}}}`
+
+var errWebhookBody = errors.New("body template did not render valid JSON")
+
+// subscribeWebhookSink posts the body template rendered with every batch of
+// the events of the types cfg selects.
+func subscribeWebhookSink(bus *eventBus, db *store, cfg webhookConfig) error {
+	text := cfg.Body
+	if text == "" {
+		text = defaultWebhookBody
+	}
+	body, err := template.New("webhook").Funcs(template.FuncMap{"json": jsonValue}).Parse(text)
+	if err != nil {
+		return err
+	}
+	header := http.Header{"Content-Type": {"application/json"}, "User-Agent": {"get-pwned-bozo"}}
+	for k, v := range cfg.Headers {
+		header.Set(k, v)
+	}
+	subscribeNotifier(bus, db, "webhook", cfg.Batch, eventFilter("webhook", cfg.Events), func(batch []notification, dropped int) error {
+		var b bytes.Buffer
+		if err := body.Execute(&b, webhookBatch{batch, dropped}); err != nil {
+			return err
+		}
+		if !json.Valid(b.Bytes()) {
+			return errWebhookBody
+		}
+		h := header.Clone()
+		if cfg.Secret != "" {
+			h.Set("X-Bozo-Signature", "sha256="+webhookSignature(cfg.Secret, b.Bytes()))
+		}
+		return post(cfg.URL, h, b.Bytes())
+	})
+	return nil
+}
+
+// webhookSignature is the hex HMAC-SHA256 of body with secret as the key.
+func webhookSignature(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// jsonValue encodes v for the body template.
+func jsonValue(v any) (string, error) {
+	b, err := json.Marshal(v)
+	return string(b), err
+}
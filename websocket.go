@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/gorilla/websocket"
+)
+
+// wsListener accepts SSH tunneled through WebSocket connections, e.g. by
+//
+//	ssh -o ProxyCommand="websocat --binary ws://host:8080/" host
+//
+// for clients behind networks that only let HTTP through. Every binary
+// message carries a chunk of the SSH stream.
+type wsListener struct {
+	addr      net.Addr
+	http      *http.Server
+	conns     chan net.Conn
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+var wsUpgrader = websocket.Upgrader{
+	// The tunnel clients aren't browsers, there is no origin to check.
+	CheckOrigin: func(*http.Request) bool { return true },
+}
+
+// listenWebSocket listens for WebSocket connections to path on address.
+func listenWebSocket(address, path string) (net.Listener, error) {
+	ln, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	l := &wsListener{addr: ln.Addr(), conns: make(chan net.Conn), done: make(chan struct{})}
+	mux := http.NewServeMux()
+	mux.HandleFunc(path, l.upgrade)
+	l.http = &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := l.http.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Error("WebSocket listener stopped", "address", l.addr, "error", err)
+		}
+	}()
+	return l, nil
+}
+
+func (l *wsListener) upgrade(w http.ResponseWriter, r *http.Request) {
+	ws, err := wsUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Debug("Could not upgrade to WebSocket", "remote", r.RemoteAddr, "error", err)
+		return
+	}
+	select {
+	case l.conns <- &wsConn{ws: ws}:
+	case <-l.done:
+		ws.Close()
+	}
+}
+
+func (l *wsListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.done:
+		return nil, net.ErrClosed
+	}
+}
+
+func (l *wsListener) Close() error {
+	var err error
+	l.closeOnce.Do(func() {
+		close(l.done)
+		err = l.http.Shutdown(context.Background())
+	})
+	return err
+}
+
+func (l *wsListener) Addr() net.Addr {
+	return l.addr
+}
+
+// wsConn is the SSH stream carried by a WebSocket connection. The addresses
+// are the ones of the underlying TCP connection, so the remote IP is known
+// like for plain connections.
+type wsConn struct {
+	ws     *websocket.Conn
+	reader io.Reader
+	// The SSH transport serializes its writes, closing the connection can
+	// race with them though.
+	writeMu sync.Mutex
+}
+
+func (c *wsConn) Read(p []byte) (int, error) {
+	for {
+		if c.reader == nil {
+			_, r, err := c.ws.NextReader()
+			if err != nil {
+				return 0, err
+			}
+			c.reader = r
+		}
+		n, err := c.reader.Read(p)
+		if errors.Is(err, io.EOF) {
+			c.reader = nil
+			if n == 0 {
+				continue
+			}
+			err = nil
+		}
+		return n, err
+	}
+}
+
+func (c *wsConn) Write(p []byte) (int, error) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if err := c.ws.WriteMessage(websocket.BinaryMessage, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (c *wsConn) Close() error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return c.ws.Close()
+}
+
+func (c *wsConn) LocalAddr() net.Addr  { return c.ws.UnderlyingConn().LocalAddr() }
+func (c *wsConn) RemoteAddr() net.Addr { return c.ws.UnderlyingConn().RemoteAddr() }
+
+func (c *wsConn) SetDeadline(t time.Time) error {
+	return errors.Join(c.ws.SetReadDeadline(t), c.ws.SetWriteDeadline(t))
+}
+
+func (c *wsConn) SetReadDeadline(t time.Time) error  { return c.ws.SetReadDeadline(t) }
+func (c *wsConn) SetWriteDeadline(t time.Time) error { return c.ws.SetWriteDeadline(t) }